@@ -58,7 +58,7 @@ func (a *Authorizer) Authorize() {
 	for a.authorizeContinuously() == statusContinue {
 	}
 
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 }
 
 func (a *Authorizer) authorizeContinuously() Status {
@@ -70,7 +70,7 @@ func (a *Authorizer) authorizeContinuously() Status {
 
 	err := ui.Init()
 	if err != nil {
-		fmt.Printf("Failed to initialize termui: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to initialize termui: %v\n", err)
 		return statusFinish
 	}
 
@@ -181,7 +181,7 @@ func (a *Authorizer) authenticateMarkedImages() Status {
 
 	credentials, err := readCredentials()
 	if err != nil {
-		fmt.Printf("Failed to read credentials, %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to read credentials, %v\n", err)
 		return statusFinish
 	}
 
@@ -205,7 +205,7 @@ func (a *Authorizer) getMarkedImageTagsAuthenticated(markedImages []*ImageChoice
 	for _, imageChoice := range markedImages {
 		tags, err := a.tagDownloader.DownloadWithAuth(imageChoice.Image, credentials)
 		if err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 			continue
 		}
 
@@ -274,7 +274,7 @@ func (a *Authorizer) unmarkRow(row int) {
 }
 
 func readCredentials() (Credentials, error) {
-	fmt.Print("Username: ")
+	fmt.Fprint(os.Stderr, "Username: ")
 
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Scan()
@@ -284,8 +284,7 @@ func readCredentials() (Credentials, error) {
 		return Credentials{}, fmt.Errorf("empty username")
 	}
 
-	fmt.Print("Password: ")
-	password, err := gopass.GetPasswdMasked()
+	password, err := gopass.GetPasswdPrompt("Password: ", true, os.Stdin, os.Stderr)
 
 	return Credentials{Username: username, Password: string(password)}, err
 }
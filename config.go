@@ -3,13 +3,68 @@ package main
 import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	All      bool
-	Insecure bool
-	Timeout  int
-	Verbose  bool
+	All                      bool
+	AllContainers            bool   `mapstructure:"all-containers"`
+	AllowedLevel             string `mapstructure:"allowed-level"`
+	Apply                    bool
+	ApplyTimeout             time.Duration `mapstructure:"apply-timeout"`
+	CaCert                   string        `mapstructure:"ca-cert"`
+	CertsDir                 string        `mapstructure:"certs-dir"`
+	DaemonInsecureRegistries bool          `mapstructure:"daemon-insecure-registries"`
+	Diff                     bool
+	DockerHosts              []string `mapstructure:"docker-hosts"`
+	Events                   bool
+	EventsDebounce           time.Duration `mapstructure:"events-debounce"`
+	Exclude                  []string
+	Files                    []string
+	Filter                   []string
+	Format                   string
+	Hosts                    []HostConfig
+	Images                   bool
+	Insecure                 bool
+	InsecureRegistries       []string `mapstructure:"insecure-registries"`
+	Interval                 time.Duration
+	Kubeconfig               string
+	Kubernetes               bool
+	KubernetesNamespaces     []string `mapstructure:"kubernetes-namespaces"`
+	KubernetesSelector       string   `mapstructure:"kubernetes-selector"`
+	Listen                   string
+	Metadata                 bool
+	MetricsFile              string        `mapstructure:"metrics-file"`
+	NoProxy                  string        `mapstructure:"no-proxy"`
+	NotificationReminder     time.Duration `mapstructure:"notification-reminder"`
+	NotificationState        string        `mapstructure:"notification-state"`
+	Output                   string
+	OutputFile               string `mapstructure:"output-file"`
+	Platform                 string
+	PlatformCheck            string `mapstructure:"platform-check"`
+	Proxy                    string
+	Registries               []RegistryConfig
+	RegistryMirrors          []string `mapstructure:"registry-mirrors"`
+	Retries                  int
+	Schedule                 string
+	Serve                    bool
+	Smtp                     SmtpConfig `mapstructure:",squash"`
+	Swarm                    bool
+	Timeout                  int
+	UpdateLevel              string `mapstructure:"update-level"`
+	Verbose                  bool
+	Watch                    bool
+	WebhookUrls              []string `mapstructure:"webhook-urls"`
+	Webhooks                 []WebhookConfig
+	Write                    bool
+}
+
+type RegistryConfig struct {
+	Name       string
+	CaCert     string `mapstructure:"ca-cert"`
+	ClientCert string `mapstructure:"client-cert"`
+	ClientKey  string `mapstructure:"client-key"`
 }
 
 func ReadConfig() Config {
@@ -17,6 +72,7 @@ func ReadConfig() Config {
 
 	setupEnvVars(v)
 	setupFlags(v)
+	readConfigFile(v)
 
 	var cfg Config
 	err := v.Unmarshal(&cfg)
@@ -29,14 +85,66 @@ func ReadConfig() Config {
 
 func setupEnvVars(v *viper.Viper) {
 	v.SetEnvPrefix("DVCHK")
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	v.AutomaticEnv()
 }
 
 func setupFlags(v *viper.Viper) {
 	pflag.BoolP("all", "a", false, "Print all newer versions")
+	pflag.Bool("all-containers", false, "Check stopped containers too")
+	pflag.String("allowed-level", UpdateNone.String(), "Report updates only above given level as failures in junit and sarif output (none, patch, minor, major)")
+	pflag.Bool("apply", false, "Update containers labelled with dvchk.autoupdate to newer versions")
+	pflag.Duration("apply-timeout", 30*time.Second, "Roll back updated containers which are not healthy within given period")
+	pflag.String("ca-cert", "", "Trust certificates signed by CA from given file")
+	pflag.String("certs-dir", defaultCertsDir, "Read registry certificates from directory in Docker certs.d layout")
+	pflag.StringP("config", "c", "", "Read configuration from given file")
+	pflag.Bool("daemon-insecure-registries", false, "Add insecure registries configured in Docker daemon")
+	pflag.Bool("diff", false, "Print unified diff of updating tags in checked files")
+	pflag.StringSlice("docker-hosts", nil, "Check containers on given Docker hosts or Docker contexts instead of the one from environment")
+	pflag.Bool("events", false, "Check started containers immediately in watch mode")
+	pflag.Duration("events-debounce", 5*time.Second, "Wait for further container events before checking started containers")
+	pflag.StringSlice("exclude", nil, "Skip containers matching any of given filters")
+	pflag.StringSlice("files", nil, "Check images referenced in given compose files and Dockerfiles instead of containers")
+	pflag.StringSlice("filter", nil, "Check only containers matching given filters (name=<pattern>, label=<key>[=<value>], compose-project=<name>, network=<name>)")
+	pflag.String("format", "", "Print results using given Go template")
+	pflag.Bool("images", false, "Check local images which are not used by checked containers")
 	pflag.BoolP("insecure", "k", false, "Disable TLS certificates validation")
+	pflag.StringSlice("insecure-registries", []string{"127.0.0.0/8"}, "Allow plain HTTP and unverified TLS for given registries or CIDRs")
+	pflag.Duration("interval", time.Hour, "Set interval between checks in watch mode")
+	pflag.String("kubeconfig", "", "Connect to Kubernetes cluster using given kubeconfig (default $KUBECONFIG or ~/.kube/config)")
+	pflag.Bool("kubernetes", false, "Check images of pods running in Kubernetes cluster instead of containers")
+	pflag.StringSlice("kubernetes-namespaces", nil, "Check pods only in given Kubernetes namespaces")
+	pflag.String("kubernetes-selector", "", "Check only pods matching given Kubernetes label selector")
+	pflag.String("listen", "127.0.0.1:8080", "Set address of HTTP server in serve mode")
+	pflag.BoolP("metadata", "m", false, "Download release date, platforms and size of newer versions")
+	pflag.String("metrics-file", "", "Write Prometheus metrics to given file after each check, e.g. for node_exporter textfile collector")
+	pflag.String("no-proxy", "", "Do not use proxy for given comma-separated hosts, domains and CIDRs")
+	pflag.Duration("notification-reminder", 0, "Notify again about pending updates after given period")
+	pflag.String("notification-state", "", "Remember notified versions in given file")
+	pflag.StringP("output", "o", OutputText, "Print results in given format (text, table, markdown, csv, junit, sarif, html)")
+	pflag.String("output-file", "", "Write results to given file instead of standard output")
+	pflag.String("platform", "", "Set platform of newer versions in os/arch[/variant] format (default Docker daemon platform)")
+	pflag.String("platform-check", "", "Flag or hide newer versions unavailable for the platform (flag, hide)")
+	pflag.String("proxy", "", "Send HTTP requests through given proxy")
+	pflag.StringSlice("registry-mirrors", nil, "Query given Docker Hub mirrors before Docker Hub")
+	pflag.IntP("retries", "r", 3, "Set number of retries for failed HTTP requests")
+	pflag.String("schedule", "", "Set cron schedule of checks in watch mode, takes precedence over interval")
+	pflag.Bool("serve", false, "Serve results of periodic checks over HTTP")
+	pflag.String("smtp-from", "", "Set sender of email notifications")
+	pflag.String("smtp-host", "", "Send email notifications through given SMTP server")
+	pflag.String("smtp-level", "", "Send email notifications only about updates of at least given level (patch, minor, major)")
+	pflag.String("smtp-password", "", "Set SMTP password")
+	pflag.Int("smtp-port", 587, "Set SMTP server port")
+	pflag.String("smtp-tls", SmtpTlsStartTls, "Set SMTP connection security (none, starttls, tls)")
+	pflag.StringSlice("smtp-to", nil, "Set recipients of email notifications")
+	pflag.String("smtp-username", "", "Set SMTP username")
+	pflag.Bool("swarm", false, "Check images of Swarm services instead of containers")
 	pflag.IntP("timeout", "t", 5, "Set timeout for HTTP requests in seconds")
+	pflag.String("update-level", UpdateMajor.String(), "Update tags in files only within given level (patch, minor, major)")
 	pflag.BoolP("verbose", "v", false, "Include additional logs")
+	pflag.BoolP("watch", "w", false, "Check periodically and report only changes between checks")
+	pflag.StringSlice("webhook-urls", nil, "Send JSON notifications about newer versions to given URLs")
+	pflag.Bool("write", false, "Update tags in checked files")
 
 	pflag.Parse()
 
@@ -45,3 +153,17 @@ func setupFlags(v *viper.Viper) {
 		panic(err)
 	}
 }
+
+func readConfigFile(v *viper.Viper) {
+	configFile := v.GetString("config")
+	if configFile == "" {
+		return
+	}
+
+	v.SetConfigFile(configFile)
+
+	err := v.ReadInConfig()
+	if err != nil {
+		panic(err)
+	}
+}
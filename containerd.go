@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	containersapi "github.com/containerd/containerd/api/services/containers/v1"
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	namespacesapi "github.com/containerd/containerd/api/services/namespaces/v1"
+	tasksapi "github.com/containerd/containerd/api/services/tasks/v1"
+	"github.com/containerd/containerd/api/types/task"
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+const (
+	containerdScheme         = "containerd://"
+	defaultContainerdAddress = "/run/containerd/containerd.sock"
+	// containerdNamespaceHeader is the gRPC metadata containerd reads the namespace of a request from.
+	containerdNamespaceHeader = "containerd-namespace"
+)
+
+// ContainerdRuntime lists containers of bare containerd through its API, in given namespaces or in all of them.
+// Containers are named namespace/id, as containerd has no container names.
+type ContainerdRuntime struct {
+	address       string
+	namespaces    []string
+	namespacesApi namespacesapi.NamespacesClient
+	containersApi containersapi.ContainersClient
+	tasksApi      tasksapi.TasksClient
+	imagesApi     imagesapi.ImagesClient
+}
+
+func NewContainerdRuntime(address string, namespaces []string) (ContainerdRuntime, error) {
+	if address == "" {
+		address = defaultContainerdAddress
+	}
+
+	// The connection is established on the first request.
+	conn, err := grpc.Dial("unix://"+address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return ContainerdRuntime{}, fmt.Errorf("failed to connect to containerd at %s, %v", address, err)
+	}
+
+	return ContainerdRuntime{
+		address:       address,
+		namespaces:    namespaces,
+		namespacesApi: namespacesapi.NewNamespacesClient(conn),
+		containersApi: containersapi.NewContainersClient(conn),
+		tasksApi:      tasksapi.NewTasksClient(conn),
+		imagesApi:     imagesapi.NewImagesClient(conn),
+	}, nil
+}
+
+func (cr ContainerdRuntime) ListContainers(all bool, containerFilters ContainerFilters) ([]types.Container, error) {
+	namespaces, err := cr.listNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []types.Container
+	for _, namespace := range namespaces {
+		namespaceContainers, err := cr.listNamespaceContainers(namespace, all)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, namespaceContainers...)
+	}
+
+	return containerFilters.Filter(containers), nil
+}
+
+func (cr ContainerdRuntime) listNamespaces() ([]string, error) {
+	if len(cr.namespaces) > 0 {
+		return cr.namespaces, nil
+	}
+
+	response, err := cr.namespacesApi.List(context.Background(), &namespacesapi.ListNamespacesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces of containerd at %s, %v", cr.address, err)
+	}
+
+	var namespaces []string
+	for _, namespace := range response.Namespaces {
+		namespaces = append(namespaces, namespace.Name)
+	}
+	return namespaces, nil
+}
+
+func (cr ContainerdRuntime) listNamespaceContainers(namespace string, all bool) ([]types.Container, error) {
+	ctx := namespaceContext(namespace)
+
+	response, err := cr.containersApi.List(ctx, &containersapi.ListContainersRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers of namespace %s, %v", namespace, err)
+	}
+
+	running := make(map[string]bool)
+	if !all {
+		tasks, err := cr.tasksApi.List(ctx, &tasksapi.ListTasksRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tasks of namespace %s, %v", namespace, err)
+		}
+		for _, process := range tasks.Tasks {
+			running[process.ContainerID] = process.Status == task.Status_RUNNING
+		}
+	}
+
+	var containers []types.Container
+	for _, container := range response.Containers {
+		// Containers without image are e.g. sandboxes of Kubernetes pods.
+		if container.Image == "" || (!all && !running[container.ID]) {
+			continue
+		}
+
+		containers = append(containers, types.Container{
+			ID:     container.ID,
+			Names:  []string{"/" + namespace + "/" + container.ID},
+			Image:  normalizeContainerdImage(container.Image),
+			Labels: container.Labels,
+		})
+	}
+
+	return containers, nil
+}
+
+func (cr ContainerdRuntime) ListImages() ([]types.ImageSummary, error) {
+	namespaces, err := cr.listNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var images []types.ImageSummary
+	for _, namespace := range namespaces {
+		response, err := cr.imagesApi.List(namespaceContext(namespace), &imagesapi.ListImagesRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list images of namespace %s, %v", namespace, err)
+		}
+
+		for _, image := range response.Images {
+			// Images pulled by digest and content of Kubernetes are referenced by digests only.
+			if strings.HasPrefix(image.Name, "sha256:") || strings.Contains(image.Name, "@") {
+				continue
+			}
+			images = append(images, types.ImageSummary{RepoTags: []string{normalizeContainerdImage(image.Name)}})
+		}
+	}
+
+	return images, nil
+}
+
+// namespaceContext returns context of requests to the namespace.
+func namespaceContext(namespace string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), containerdNamespaceHeader, namespace)
+}
+
+// normalizeContainerdImage shortens fully qualified Docker Hub references used by containerd, e.g.
+// docker.io/library/nginx:1.25.3 to nginx:1.25.3, like Docker shows them.
+func normalizeContainerdImage(image string) string {
+	image = strings.TrimPrefix(image, "docker.io/library/")
+	return strings.TrimPrefix(image, "docker.io/")
+}
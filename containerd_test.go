@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	containersapi "github.com/containerd/containerd/api/services/containers/v1"
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	namespacesapi "github.com/containerd/containerd/api/services/namespaces/v1"
+	tasksapi "github.com/containerd/containerd/api/services/tasks/v1"
+	"github.com/containerd/containerd/api/types/task"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"reflect"
+	"testing"
+)
+
+// fakeContainerd serves lists of containerd API by namespaces, other methods of its clients are not implemented.
+type fakeContainerd struct {
+	namespaces []string
+	containers map[string][]*containersapi.Container
+	tasks      map[string][]*task.Process
+	images     map[string][]*imagesapi.Image
+	// requests are requests of the fake in order, as namespace/service.
+	requests *[]string
+}
+
+func newTestContainerdRuntime(namespaces []string, fake fakeContainerd) (ContainerdRuntime, *[]string) {
+	fake.requests = &[]string{}
+	runtime := ContainerdRuntime{
+		namespaces:    namespaces,
+		namespacesApi: fakeNamespaces{fakeContainerd: fake},
+		containersApi: fakeContainers{fakeContainerd: fake},
+		tasksApi:      fakeTasks{fakeContainerd: fake},
+		imagesApi:     fakeImages{fakeContainerd: fake},
+	}
+	return runtime, fake.requests
+}
+
+func (fc fakeContainerd) request(ctx context.Context, service string) (string, error) {
+	namespace := ""
+	if md, present := metadata.FromOutgoingContext(ctx); present && len(md.Get(containerdNamespaceHeader)) == 1 {
+		namespace = md.Get(containerdNamespaceHeader)[0]
+	}
+	*fc.requests = append(*fc.requests, namespace+"/"+service)
+
+	if namespace == "" && service != "namespaces" {
+		return "", fmt.Errorf("namespace is required")
+	}
+	return namespace, nil
+}
+
+type fakeNamespaces struct {
+	namespacesapi.NamespacesClient
+	fakeContainerd
+}
+
+func (fn fakeNamespaces) List(ctx context.Context, in *namespacesapi.ListNamespacesRequest, opts ...grpc.CallOption) (*namespacesapi.ListNamespacesResponse, error) {
+	_, _ = fn.request(ctx, "namespaces")
+
+	response := &namespacesapi.ListNamespacesResponse{}
+	for _, namespace := range fn.namespaces {
+		response.Namespaces = append(response.Namespaces, &namespacesapi.Namespace{Name: namespace})
+	}
+	return response, nil
+}
+
+type fakeContainers struct {
+	containersapi.ContainersClient
+	fakeContainerd
+}
+
+func (fc fakeContainers) List(ctx context.Context, in *containersapi.ListContainersRequest, opts ...grpc.CallOption) (*containersapi.ListContainersResponse, error) {
+	namespace, err := fc.request(ctx, "containers")
+	if err != nil {
+		return nil, err
+	}
+	return &containersapi.ListContainersResponse{Containers: fc.containers[namespace]}, nil
+}
+
+type fakeTasks struct {
+	tasksapi.TasksClient
+	fakeContainerd
+}
+
+func (ft fakeTasks) List(ctx context.Context, in *tasksapi.ListTasksRequest, opts ...grpc.CallOption) (*tasksapi.ListTasksResponse, error) {
+	namespace, err := ft.request(ctx, "tasks")
+	if err != nil {
+		return nil, err
+	}
+	return &tasksapi.ListTasksResponse{Tasks: ft.tasks[namespace]}, nil
+}
+
+type fakeImages struct {
+	imagesapi.ImagesClient
+	fakeContainerd
+}
+
+func (fi fakeImages) List(ctx context.Context, in *imagesapi.ListImagesRequest, opts ...grpc.CallOption) (*imagesapi.ListImagesResponse, error) {
+	namespace, err := fi.request(ctx, "images")
+	if err != nil {
+		return nil, err
+	}
+	return &imagesapi.ListImagesResponse{Images: fi.images[namespace]}, nil
+}
+
+func TestContainerdRuntimeListsRunningContainers(t *testing.T) {
+	runtime, _ := newTestContainerdRuntime(nil, fakeContainerd{
+		namespaces: []string{"default", "batch"},
+		containers: map[string][]*containersapi.Container{
+			"default": {
+				{ID: "api", Image: "docker.io/author/api:1.0.0"},
+				{ID: "web", Image: "docker.io/library/nginx:1.25.3"},
+				{ID: "sandbox"},
+			},
+			"batch": {{ID: "report", Image: "registry.com/batch/report:2.1.0"}},
+		},
+		tasks: map[string][]*task.Process{
+			"default": {
+				{ContainerID: "api", Pid: 1234, Status: task.Status_RUNNING},
+				{ContainerID: "web", Pid: 1240, Status: task.Status_STOPPED},
+			},
+		},
+	})
+
+	containers, err := runtime.ListContainers(false, ContainerFilters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, container := range containers {
+		names = append(names, containerName(container)+"="+container.Image)
+	}
+	if expected := []string{"default/api=author/api:1.0.0"}; !reflect.DeepEqual(expected, names) {
+		t.Errorf("Should be %v, but is %v", expected, names)
+	}
+
+	containers, err = runtime.ListContainers(true, ContainerFilters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 3 {
+		t.Errorf("Should list 3 containers, but is %v", containers)
+	}
+}
+
+func TestContainerdRuntimeFiltersByLabels(t *testing.T) {
+	runtime, requests := newTestContainerdRuntime([]string{"default"}, fakeContainerd{
+		containers: map[string][]*containersapi.Container{
+			"default": {
+				{ID: "api", Image: "docker.io/author/api:1.0.0", Labels: map[string]string{"team": "payments"}},
+				{ID: "db", Image: "docker.io/library/postgres:15.3", Labels: map[string]string{"team": "storage"}},
+			},
+		},
+	})
+
+	containerFilters, _ := ParseContainerFilters([]string{"label=team=payments"}, nil)
+	containers, err := runtime.ListContainers(true, containerFilters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(containers) != 1 || containers[0].ID != "api" {
+		t.Errorf("Should list api container, but is %v", containers)
+	}
+	if expected := []string{"default/containers"}; !reflect.DeepEqual(expected, *requests) {
+		t.Errorf("Should not list namespaces given in config, but is %v", *requests)
+	}
+}
+
+func TestContainerdRuntimeListsTaggedImages(t *testing.T) {
+	runtime, _ := newTestContainerdRuntime([]string{"k8s.io"}, fakeContainerd{
+		images: map[string][]*imagesapi.Image{
+			"k8s.io": {
+				{Name: "docker.io/library/redis:7.0.0"},
+				{Name: "registry.k8s.io/pause:3.9"},
+				{Name: "registry.k8s.io/pause@sha256:7031c1b283388d2c2e09b57badb803c05ebed362dc88d84b480cc47f72a21097"},
+				{Name: "sha256:e6f1816883972d4be47bd48879a08919b96afcd344132622e4d444987919323c"},
+			},
+		},
+	})
+
+	images, err := runtime.ListImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imageNames := localImageNames(images, nil)
+	if expected := []string{"redis:7.0.0", "registry.k8s.io/pause:3.9"}; !reflect.DeepEqual(expected, imageNames) {
+		t.Errorf("Should be %v, but is %v", expected, imageNames)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/docker/docker/client"
+	"golang.org/x/net/context"
+)
+
+// readDaemonInsecureRegistries returns registries and CIDRs marked as insecure in Docker daemon configuration.
+func readDaemonInsecureRegistries(cli *client.Client) ([]string, error) {
+	info, err := cli.Info(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	if info.RegistryConfig == nil {
+		return nil, nil
+	}
+
+	var insecureRegistries []string
+	for _, cidr := range info.RegistryConfig.InsecureRegistryCIDRs {
+		insecureRegistries = append(insecureRegistries, cidr.String())
+	}
+
+	for _, index := range info.RegistryConfig.IndexConfigs {
+		if !index.Secure {
+			insecureRegistries = append(insecureRegistries, index.Name)
+		}
+	}
+
+	return insecureRegistries, nil
+}
+
+// readDaemonPlatform returns platform of the host Docker daemon runs on.
+func readDaemonPlatform(cli *client.Client) (Platform, error) {
+	info, err := cli.Info(context.Background())
+	if err != nil {
+		return Platform{}, err
+	}
+
+	return NormalizePlatform(info.OSType, info.Architecture), nil
+}
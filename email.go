@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	SmtpTlsNone     = "none"
+	SmtpTlsStartTls = "starttls"
+	SmtpTlsTls      = "tls"
+)
+
+type SmtpConfig struct {
+	Host     string   `mapstructure:"smtp-host"`
+	Port     int      `mapstructure:"smtp-port"`
+	Tls      string   `mapstructure:"smtp-tls"`
+	Username string   `mapstructure:"smtp-username"`
+	Password string   `mapstructure:"smtp-password"`
+	From     string   `mapstructure:"smtp-from"`
+	To       []string `mapstructure:"smtp-to"`
+	Level    string   `mapstructure:"smtp-level"`
+}
+
+// emailLevels are update levels in the order they are grouped in emails.
+var emailLevels = []UpdateLevel{UpdateMajor, UpdateMinor, UpdatePatch, UpdateNone}
+
+type emailGroup struct {
+	Level  string
+	Images []NotificationImage
+}
+
+type emailContent struct {
+	Title  string
+	Groups []emailGroup
+}
+
+var emailTextTemplate = template.Must(template.New("text").Funcs(template.FuncMap{"join": strings.Join}).Parse(`{{ .Title }}
+{{ range .Groups }}
+{{ .Level }} updates:
+{{ range .Images }}  {{ .Image }}{{ if .Container }} [{{ .ContainerKey }}]{{ else if .Host }} on {{ .Host }}{{ end }}: {{ join .NewerVersions ", " }}
+{{ end }}{{ end }}`))
+
+var emailHtmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap{"join": strings.Join}).Parse(`<html>
+<body>
+<h2>{{ .Title }}</h2>
+{{ range .Groups }}<h3>{{ .Level }} updates</h3>
+<table>
+<tr><th>Image</th><th>Container</th><th>Newer versions</th></tr>
+{{ range .Images }}<tr><td>{{ .Image }}</td><td>{{ .ContainerKey }}</td><td>{{ join .NewerVersions ", " }}</td></tr>
+{{ end }}</table>
+{{ end }}</body>
+</html>
+`))
+
+// EmailNotifier sends notifications as multipart plain text and HTML emails through a SMTP server.
+type EmailNotifier struct {
+	config    SmtpConfig
+	tlsConfig *tls.Config
+	timeout   time.Duration
+}
+
+func NewEmailNotifier(config Config) (*EmailNotifier, error) {
+	smtpConfig := config.Smtp
+
+	if smtpConfig.Tls != SmtpTlsNone && smtpConfig.Tls != SmtpTlsStartTls && smtpConfig.Tls != SmtpTlsTls {
+		return nil, fmt.Errorf("%s is invalid SMTP TLS mode", smtpConfig.Tls)
+	}
+	if smtpConfig.From == "" || len(smtpConfig.To) == 0 {
+		return nil, fmt.Errorf("SMTP sender and recipients are required")
+	}
+
+	tlsConfig, err := createBaseTlsConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ServerName = smtpConfig.Host
+
+	return &EmailNotifier{config: smtpConfig, tlsConfig: tlsConfig, timeout: time.Duration(config.Timeout) * time.Second}, nil
+}
+
+func (en *EmailNotifier) Name() string {
+	return "smtp://" + en.address()
+}
+
+func (en *EmailNotifier) address() string {
+	return net.JoinHostPort(en.config.Host, strconv.Itoa(en.config.Port))
+}
+
+func (en *EmailNotifier) Notify(notification Notification) error {
+	message, err := en.createMessage(notification)
+	if err != nil {
+		return err
+	}
+
+	client, err := en.connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if en.config.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", en.config.Username, en.config.Password, en.config.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(en.config.From)
+	if err != nil {
+		return err
+	}
+	for _, to := range en.config.To {
+		err = client.Rcpt(to)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(message)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (en *EmailNotifier) connect() (*smtp.Client, error) {
+	dialer := &net.Dialer{Timeout: en.timeout}
+
+	var conn net.Conn
+	var err error
+	if en.config.Tls == SmtpTlsTls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", en.address(), en.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", en.address())
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Servers stalling after accepting the connection must not block the check.
+	err = conn.SetDeadline(time.Now().Add(en.timeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, en.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if en.config.Tls == SmtpTlsStartTls {
+		if supported, _ := client.Extension("STARTTLS"); !supported {
+			client.Close()
+			return nil, fmt.Errorf("SMTP server %s does not support STARTTLS", en.address())
+		}
+
+		err = client.StartTLS(en.tlsConfig)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+func (en *EmailNotifier) createMessage(notification Notification) ([]byte, error) {
+	content := groupByLevel(notification)
+
+	var text, html bytes.Buffer
+	err := emailTextTemplate.Execute(&text, content)
+	if err != nil {
+		return nil, err
+	}
+	err = emailHtmlTemplate.Execute(&html, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{{"text/plain", text.Bytes()}, {"text/html", html.Bytes()}} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+		_, err = encoder.Write(part.content)
+		if err != nil {
+			return nil, err
+		}
+		encoder.Close()
+	}
+	parts.Close()
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", en.config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(en.config.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", content.Title)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+func groupByLevel(notification Notification) emailContent {
+	content := emailContent{Title: notification.Title()}
+
+	for _, level := range emailLevels {
+		group := emailGroup{Level: level.String()}
+		for _, image := range notification.Images {
+			if image.Level == group.Level {
+				group.Images = append(group.Images, image)
+			}
+		}
+
+		if len(group.Images) > 0 {
+			content.Groups = append(content.Groups, group)
+		}
+	}
+
+	return content
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type receivedEmail struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSmtpSink accepts a single SMTP session and sends the received email to the channel.
+func startSmtpSink(t *testing.T) (string, int, <-chan receivedEmail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emails := make(chan receivedEmail, 1)
+
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		var email receivedEmail
+
+		_ = text.PrintfLine("220 localhost ESMTP sink")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO", "HELO":
+				_ = text.PrintfLine("250 localhost")
+			case "MAIL":
+				email.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+				_ = text.PrintfLine("250 OK")
+			case "RCPT":
+				email.to = append(email.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+				_ = text.PrintfLine("250 OK")
+			case "DATA":
+				_ = text.PrintfLine("354 Send data")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				email.data = string(data)
+				_ = text.PrintfLine("250 OK")
+			case "QUIT":
+				_ = text.PrintfLine("221 Bye")
+				emails <- email
+				return
+			default:
+				_ = text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	return host, portNumber, emails
+}
+
+func TestEmailNotifierSendsMultipartEmail(t *testing.T) {
+	host, port, emails := startSmtpSink(t)
+
+	config := Config{Timeout: 5, Smtp: SmtpConfig{
+		Host: host, Port: port, Tls: SmtpTlsNone, From: "dvchk@example.com", To: []string{"ops@example.com", "dev@example.com"},
+	}}
+	notifiers, err := CreateNotifiers(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifiers.Notify(CheckResult{ImagesNewerVersions: testNotificationResults})
+	var email receivedEmail
+	select {
+	case email = <-emails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Should send email, but none was received within 5s")
+	}
+
+	if email.from != "dvchk@example.com" || !reflect.DeepEqual([]string{"ops@example.com", "dev@example.com"}, email.to) {
+		t.Errorf("Should be sent from dvchk@example.com to ops@ and dev@, but is from %s to %v", email.from, email.to)
+	}
+
+	message, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(email.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subject := message.Header.Get("Subject"); subject != "There are new versions of 2 images" {
+		t.Errorf("Should be There are new versions of 2 images, but is %s", subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Should be multipart/alternative, but is %s, %v", mediaType, err)
+	}
+
+	parts := make(map[string]string)
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		content, _ := ioutil.ReadAll(part)
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[partType] = string(content)
+	}
+
+	expectedText := `There are new versions of 2 images
+
+major updates:
+  author/api:1.0.0 [api]: 1.0.1, 2.0.0
+
+minor updates:
+  postgres:15.3 [db]: 15.4
+`
+	if parts["text/plain"] != expectedText {
+		t.Errorf("Should be %s, but is %s", expectedText, parts["text/plain"])
+	}
+
+	if !strings.Contains(parts["text/html"], "<h3>major updates</h3>") || !strings.Contains(parts["text/html"], "<td>postgres:15.3</td>") {
+		t.Errorf("Should contain grouped images, but is %s", parts["text/html"])
+	}
+}
+
+func TestEmailNotifierTimesOutOnStalledServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The server accepts connections, but never greets.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	notifier, err := NewEmailNotifier(Config{Timeout: 1, Smtp: SmtpConfig{
+		Host: host, Port: portNumber, Tls: SmtpTlsNone, From: "dvchk@example.com", To: []string{"ops@example.com"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := make(chan error, 1)
+	go func() {
+		_, err := notifier.connect()
+		failed <- err
+	}()
+
+	select {
+	case err = <-failed:
+		if err == nil {
+			t.Error("Should fail when server does not respond")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Should time out after 1s, but still waits after 5s")
+	}
+}
+
+func TestNewEmailNotifierFailsOnInvalidConfig(t *testing.T) {
+	invalid := []SmtpConfig{
+		{Host: "localhost", Tls: "ssl", From: "dvchk@example.com", To: []string{"ops@example.com"}},
+		{Host: "localhost", Tls: SmtpTlsTls, To: []string{"ops@example.com"}},
+		{Host: "localhost", Tls: SmtpTlsTls, From: "dvchk@example.com"},
+	}
+
+	for _, smtpConfig := range invalid {
+		if _, err := NewEmailNotifier(Config{Smtp: smtpConfig}); err == nil {
+			t.Errorf("Should fail for %v", smtpConfig)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"os"
+	"time"
+)
+
+const (
+	eventStart   = "start"
+	eventDestroy = "destroy"
+
+	eventsReconnectDelay = 10 * time.Second
+)
+
+// ContainerEvents is a batch of container events received within the debounce period.
+type ContainerEvents struct {
+	// Started holds IDs of started containers.
+	Started []string
+	// Destroyed holds names of destroyed containers.
+	Destroyed []string
+}
+
+// EventListener subscribes to Docker events of started and destroyed containers, e.g. to check
+// containers recreated by compose without waiting for the next scheduled check.
+type EventListener struct {
+	cli      *client.Client
+	debounce time.Duration
+}
+
+func NewEventListener(cli *client.Client, debounce time.Duration) EventListener {
+	return EventListener{cli: cli, debounce: debounce}
+}
+
+// Listen sends batches of container events until the context is done, reconnecting when the event
+// stream fails.
+func (el EventListener) Listen(ctx context.Context) <-chan ContainerEvents {
+	messages := make(chan events.Message)
+	batches := make(chan ContainerEvents)
+
+	go el.receive(ctx, messages)
+	go debounceEvents(ctx, messages, batches, el.debounce)
+
+	return batches
+}
+
+func (el EventListener) receive(ctx context.Context, messages chan<- events.Message) {
+	eventFilters := filters.NewArgs()
+	eventFilters.Add("type", events.ContainerEventType)
+	eventFilters.Add("event", eventStart)
+	eventFilters.Add("event", eventDestroy)
+
+	for {
+		eventMessages, errs := el.cli.Events(ctx, types.EventsOptions{Filters: eventFilters})
+
+		err := forwardEvents(ctx, eventMessages, errs, messages)
+		if ctx.Err() != nil {
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "Docker events stream failed, reconnecting in %v, %v\n", eventsReconnectDelay, err)
+
+		select {
+		case <-time.After(eventsReconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func forwardEvents(ctx context.Context, eventMessages <-chan events.Message, errs <-chan error, messages chan<- events.Message) error {
+	for {
+		select {
+		case message := <-eventMessages:
+			select {
+			case messages <- message:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// debounceEvents collects events until no new event arrives for the debounce period, so that starting
+// many containers at once results in a single batch.
+func debounceEvents(ctx context.Context, messages <-chan events.Message, batches chan<- ContainerEvents, debounce time.Duration) {
+	var batch ContainerEvents
+	var timeout <-chan time.Time
+
+	for {
+		select {
+		case message := <-messages:
+			log.Debugf("Received %s event of container %s\n", message.Action, message.Actor.ID)
+
+			switch message.Action {
+			case eventStart:
+				batch.Started = append(batch.Started, message.Actor.ID)
+			case eventDestroy:
+				batch.Destroyed = append(batch.Destroyed, message.Actor.Attributes["name"])
+			}
+
+			timeout = time.After(debounce)
+		case <-timeout:
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return
+			}
+
+			batch = ContainerEvents{}
+			timeout = nil
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func getContainersById(cli *client.Client, ids []string) ([]types.Container, error) {
+	containerFilters := filters.NewArgs()
+	for _, id := range ids {
+		containerFilters.Add("id", id)
+	}
+
+	return cli.ContainerList(context.Background(), types.ContainerListOptions{Filters: containerFilters})
+}
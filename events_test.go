@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/docker/docker/api/types/events"
+	"golang.org/x/net/context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDebounceEventsBatchesEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan events.Message)
+	batches := make(chan ContainerEvents)
+	go debounceEvents(ctx, messages, batches, 50*time.Millisecond)
+
+	for _, id := range []string{"a", "b", "c"} {
+		messages <- events.Message{Action: eventStart, Actor: events.Actor{ID: id}}
+	}
+	messages <- events.Message{Action: eventDestroy, Actor: events.Actor{ID: "d", Attributes: map[string]string{"name": "old"}}}
+
+	expected := ContainerEvents{Started: []string{"a", "b", "c"}, Destroyed: []string{"old"}}
+
+	select {
+	case batch := <-batches:
+		if !reflect.DeepEqual(expected, batch) {
+			t.Errorf("Should be %v, but is %v", expected, batch)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Should receive batch of events")
+	}
+}
+
+func TestApplyContainerChanges(t *testing.T) {
+	previous := CheckResult{
+		Containers: map[string]string{"api": "author/api:1.0.0", "db": "postgres:15.3"},
+		ImagesNewerVersions: ImagesNewerVersions{
+			newTestNewerVersions("author/api:1.0.0", "api", "1.1.0"),
+			newTestNewerVersions("postgres:15.3", "db", "15.4"),
+		},
+	}
+	partial := CheckResult{
+		Containers:          map[string]string{"web": "nginx:1.25.0"},
+		ImagesNewerVersions: ImagesNewerVersions{newTestNewerVersions("nginx:1.25.0", "web", "1.25.1")},
+	}
+
+	current := applyContainerChanges(previous, partial, []string{"api"})
+
+	expected := []string{
+		"Container api running author/api:1.0.0 is gone",
+		"Container web running nginx:1.25.0 was started",
+		"There are new versions of nginx:1.25.0 [web]! New versions: [1.25.1]",
+	}
+
+	changes := diffResults(previous, current)
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("Should be %v, but is %v", expected, changes)
+	}
+}
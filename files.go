@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const diffContextLines = 3
+
+var (
+	composeImagePattern    = regexp.MustCompile(`^(\s*-?\s*image:\s*["']?)([^"'\s#]+)`)
+	dockerfileFromPattern  = regexp.MustCompile(`(?i)^(\s*FROM\s+(?:--\S+\s+)*)(\S+)(?:\s+AS\s+(\S+))?`)
+	dockerfileArgPattern   = regexp.MustCompile(`^(\s*ARG\s+(\w+)=)([^\s"']+)`)
+	defaultVariablePattern = regexp.MustCompile(`^\$\{\w+:?-([^}]+)\}$`)
+	variablePattern        = regexp.MustCompile(`^\$\{?(\w+)\}?$`)
+)
+
+// FileReference is an image referenced in a compose file or a Dockerfile, together with position of its tag, so that
+// the tag can be replaced without touching the rest of the file.
+type FileReference struct {
+	Path string
+	// Line is the number of the line, starting from 1, containing the tag. It can differ from the line referencing
+	// the image, when the tag is a default value of a variable.
+	Line  int
+	Image string
+
+	tagStart int
+	tagEnd   int
+}
+
+// FileUpdate replaces the tag of a file reference with a newer version.
+type FileUpdate struct {
+	FileReference
+	Tag string
+}
+
+// ReadFileReferences reads images from Dockerfiles, recognized by name, and compose files.
+func ReadFileReferences(paths []string) ([]FileReference, error) {
+	var references []FileReference
+
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		lines := strings.Split(string(content), "\n")
+		if isDockerfile(path) {
+			references = append(references, parseDockerfile(path, lines)...)
+		} else {
+			references = append(references, parseComposeFile(path, lines)...)
+		}
+	}
+
+	return references, nil
+}
+
+func isDockerfile(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	return strings.Contains(name, "dockerfile") || strings.Contains(name, "containerfile")
+}
+
+// ImageNames returns distinct images of references.
+func ImageNames(references []FileReference) []string {
+	var imageNames []string
+	seen := make(map[string]bool)
+
+	for _, reference := range references {
+		if !seen[reference.Image] {
+			seen[reference.Image] = true
+			imageNames = append(imageNames, reference.Image)
+		}
+	}
+
+	return imageNames
+}
+
+func parseComposeFile(path string, lines []string) []FileReference {
+	var references []FileReference
+
+	for i, line := range lines {
+		match := composeImagePattern.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+
+		reference, ok := parseImageReference(line[match[4]:match[5]], match[4], nil)
+		if !ok {
+			continue
+		}
+
+		reference.Path = path
+		reference.Line = i + 1
+		references = append(references, reference)
+	}
+
+	return references
+}
+
+func parseDockerfile(path string, lines []string) []FileReference {
+	var references []FileReference
+
+	args := make(map[string]FileReference)
+	stages := make(map[string]bool)
+
+	for i, line := range lines {
+		if match := dockerfileArgPattern.FindStringSubmatchIndex(line); match != nil {
+			args[line[match[4]:match[5]]] = FileReference{Path: path, Line: i + 1, tagStart: match[6], tagEnd: match[7]}
+			continue
+		}
+
+		match := dockerfileFromPattern.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+
+		if match[6] >= 0 {
+			stages[strings.ToLower(line[match[6]:match[7]])] = true
+		}
+
+		imageName := line[match[4]:match[5]]
+		if stages[strings.ToLower(imageName)] || imageName == "scratch" {
+			continue
+		}
+
+		argValues := make(map[string]FileReference)
+		for name, arg := range args {
+			arg.Image = lines[arg.Line-1][arg.tagStart:arg.tagEnd]
+			argValues[name] = arg
+		}
+
+		reference, ok := parseImageReference(imageName, match[4], argValues)
+		if !ok {
+			continue
+		}
+
+		if reference.Path == "" {
+			reference.Path = path
+			reference.Line = i + 1
+		}
+		references = append(references, reference)
+	}
+
+	return references
+}
+
+// parseImageReference finds the tag of the image, which starts at given offset of the line. Tags given by variables
+// are supported when the variable has a default value or is a known build argument, which is then updated instead.
+func parseImageReference(imageName string, offset int, args map[string]FileReference) (FileReference, bool) {
+	if strings.Contains(imageName, "@") {
+		return FileReference{}, false
+	}
+
+	// Registry port is followed by a slash, default values of variables can contain colons and slashes.
+	prefix := imageName
+	if variable := strings.Index(imageName, "$"); variable >= 0 {
+		prefix = imageName[:variable]
+	}
+	slash := strings.LastIndex(prefix, "/")
+	separator := strings.Index(imageName[slash+1:], ":")
+	if separator < 0 {
+		return FileReference{}, false
+	}
+	separator += slash + 1
+
+	name, tag := imageName[:separator], imageName[separator+1:]
+	if strings.Contains(name, "$") {
+		return FileReference{}, false
+	}
+
+	tagStart := offset + separator + 1
+	if match := defaultVariablePattern.FindStringSubmatchIndex(tag); match != nil {
+		tagStart += match[2]
+		tag = tag[match[2]:match[3]]
+		return FileReference{Image: name + ":" + tag, tagStart: tagStart, tagEnd: tagStart + len(tag)}, true
+	}
+
+	if match := variablePattern.FindStringSubmatch(tag); match != nil {
+		arg, present := args[match[1]]
+		if !present {
+			return FileReference{}, false
+		}
+
+		arg.Image = name + ":" + arg.Image
+		return arg, true
+	}
+
+	if strings.Contains(tag, "$") {
+		return FileReference{}, false
+	}
+
+	return FileReference{Image: imageName, tagStart: tagStart, tagEnd: tagStart + len(tag)}, true
+}
+
+// PlanFileUpdates selects the highest newer version within the update level for each reference.
+func PlanFileUpdates(references []FileReference, imagesNewerVersions ImagesNewerVersions, level UpdateLevel) []FileUpdate {
+	newerVersions := make(map[string]ImageNewerVersions)
+	for _, inv := range imagesNewerVersions {
+		newerVersions[inv.imageName] = inv
+	}
+
+	var updates []FileUpdate
+	updated := make(map[string]bool)
+
+	for _, reference := range references {
+		inv, present := newerVersions[reference.Image]
+		if !present {
+			continue
+		}
+
+		// Build arguments can be referenced by many stages.
+		position := fmt.Sprintf("%s:%d", reference.Path, reference.Line)
+		if updated[position] {
+			continue
+		}
+
+		tag := selectUpdateTag(inv, level)
+		if tag == "" {
+			continue
+		}
+
+		updated[position] = true
+		updates = append(updates, FileUpdate{FileReference: reference, Tag: tag})
+	}
+
+	return updates
+}
+
+// ApplyFileUpdates writes updated files and/or a unified diff of them.
+func ApplyFileUpdates(updates []FileUpdate, write bool, diff io.Writer) error {
+	var paths []string
+	updatesByPath := make(map[string][]FileUpdate)
+	for _, update := range updates {
+		if _, present := updatesByPath[update.Path]; !present {
+			paths = append(paths, update.Path)
+		}
+		updatesByPath[update.Path] = append(updatesByPath[update.Path], update)
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		before := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+		after := make([]string, len(before))
+		copy(after, before)
+
+		for _, update := range updatesByPath[path] {
+			line := after[update.Line-1]
+			after[update.Line-1] = line[:update.tagStart] + update.Tag + line[update.tagEnd:]
+		}
+
+		if diff != nil {
+			_, err = io.WriteString(diff, unifiedDiff(path, before, after))
+			if err != nil {
+				return err
+			}
+		}
+
+		if write {
+			updated := strings.Join(after, "\n")
+			if strings.HasSuffix(string(content), "\n") {
+				updated += "\n"
+			}
+
+			err = ioutil.WriteFile(path, []byte(updated), info.Mode())
+			if err != nil {
+				return err
+			}
+
+			for _, update := range updatesByPath[path] {
+				fmt.Fprintf(os.Stderr, "Updated %s to %s in %s:%d\n", update.Image, update.Tag, update.Path, update.Line)
+			}
+		}
+	}
+
+	return nil
+}
+
+// unifiedDiff describes changes of lines, which are only replaced, never added or removed.
+func unifiedDiff(path string, before []string, after []string) string {
+	var changed []int
+	for i := range before {
+		if before[i] != after[i] {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n", filepath.ToSlash(path), filepath.ToSlash(path))
+
+	for i := 0; i < len(changed); {
+		// Changes closer than twice the context share a hunk.
+		j := i
+		for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*diffContextLines {
+			j++
+		}
+
+		start := changed[i] - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := changed[j] + diffContextLines + 1
+		if end > len(before) {
+			end = len(before)
+		}
+		fmt.Fprintf(&diff, "@@ -%d,%d +%d,%d @@\n", start+1, end-start, start+1, end-start)
+
+		for line := start; line < end; line++ {
+			if before[line] == after[line] {
+				fmt.Fprintf(&diff, " %s\n", before[line])
+			} else {
+				fmt.Fprintf(&diff, "-%s\n+%s\n", before[line], after[line])
+			}
+		}
+
+		i = j + 1
+	}
+
+	return diff.String()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testComposeFile = `services:
+  db:
+    image: "postgres:15.3" # pinned until migration
+  cache:
+    image: redis:${REDIS_VERSION:-7.0.0}
+  api:
+    image: localhost:5000/api@sha256:abc
+  web:
+    image: nginx
+`
+
+const testDockerfile = `ARG GO_VERSION=1.21.0
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+RUN go build
+FROM build AS test
+FROM alpine:3.18.2
+COPY --from=build /app /app
+`
+
+func writeTestFiles(t *testing.T) (string, string, string) {
+	dir, err := ioutil.TempDir("", "dvchk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	composeFile := filepath.Join(dir, "docker-compose.yml")
+	dockerfile := filepath.Join(dir, "Dockerfile")
+	for path, content := range map[string]string{composeFile: testComposeFile, dockerfile: testDockerfile} {
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir, composeFile, dockerfile
+}
+
+func TestReadFileReferences(t *testing.T) {
+	dir, composeFile, dockerfile := writeTestFiles(t)
+	defer os.RemoveAll(dir)
+
+	references, err := ReadFileReferences([]string{composeFile, dockerfile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []FileReference{
+		{Path: composeFile, Line: 3, Image: "postgres:15.3", tagStart: 21, tagEnd: 25},
+		{Path: composeFile, Line: 5, Image: "redis:7.0.0", tagStart: 34, tagEnd: 39},
+		{Path: dockerfile, Line: 1, Image: "golang:1.21.0", tagStart: 15, tagEnd: 21},
+		{Path: dockerfile, Line: 5, Image: "alpine:3.18.2", tagStart: 12, tagEnd: 18},
+	}
+	if !reflect.DeepEqual(expected, references) {
+		t.Errorf("Should be %v, but is %v", expected, references)
+	}
+}
+
+func TestApplyFileUpdates(t *testing.T) {
+	dir, composeFile, dockerfile := writeTestFiles(t)
+	defer os.RemoveAll(dir)
+
+	references, err := ReadFileReferences([]string{composeFile, dockerfile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imagesNewerVersions := ImagesNewerVersions{
+		newTestNewerVersions("postgres:15.3", "", "15.4", "16.0"),
+		newTestNewerVersions("redis:7.0.0", "", "7.0.1", "7.2.0"),
+		newTestNewerVersions("golang:1.21.0", "", "1.21.5"),
+		newTestNewerVersions("alpine:3.18.2", ""),
+	}
+
+	var diff bytes.Buffer
+	updates := PlanFileUpdates(references, imagesNewerVersions, UpdateMinor)
+	err = ApplyFileUpdates(updates, true, &diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedCompose := `services:
+  db:
+    image: "postgres:15.4" # pinned until migration
+  cache:
+    image: redis:${REDIS_VERSION:-7.2.0}
+  api:
+    image: localhost:5000/api@sha256:abc
+  web:
+    image: nginx
+`
+	if content, _ := ioutil.ReadFile(composeFile); string(content) != expectedCompose {
+		t.Errorf("Should be %s, but is %s", expectedCompose, content)
+	}
+
+	expectedDiff := `--- a/` + filepath.ToSlash(composeFile) + `
++++ b/` + filepath.ToSlash(composeFile) + `
+@@ -1,8 +1,8 @@
+ services:
+   db:
+-    image: "postgres:15.3" # pinned until migration
++    image: "postgres:15.4" # pinned until migration
+   cache:
+-    image: redis:${REDIS_VERSION:-7.0.0}
++    image: redis:${REDIS_VERSION:-7.2.0}
+   api:
+     image: localhost:5000/api@sha256:abc
+   web:
+--- a/` + filepath.ToSlash(dockerfile) + `
++++ b/` + filepath.ToSlash(dockerfile) + `
+@@ -1,4 +1,4 @@
+-ARG GO_VERSION=1.21.0
++ARG GO_VERSION=1.21.5
+ FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+ RUN go build
+ FROM build AS test
+`
+	if diff.String() != expectedDiff {
+		t.Errorf("Should be %s, but is %s", expectedDiff, diff.String())
+	}
+}
+
+func TestWriteResultsPrintsDiffInsteadOfReport(t *testing.T) {
+	dir, composeFile, dockerfile := writeTestFiles(t)
+	defer os.RemoveAll(dir)
+
+	references, err := ReadFileReferences([]string{composeFile, dockerfile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := CheckResult{ImagesNewerVersions: ImagesNewerVersions{newTestNewerVersions("postgres:15.3", "", "15.4")}}
+	report := NewReport(result, time.Now())
+	updates := PlanFileUpdates(references, result.ImagesNewerVersions, UpdateMinor)
+
+	var expectedDiff, expectedReport bytes.Buffer
+	_ = ApplyFileUpdates(updates, false, &expectedDiff)
+	_ = TextReporter{}.Report(&expectedReport, report)
+
+	stdout := captureStdout(t, func() {
+		err = writeResults(TextReporter{}, report, updates, Config{Diff: true})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != expectedDiff.String() {
+		t.Errorf("Should be only diff %s, but is %s", expectedDiff.String(), stdout)
+	}
+
+	outputFile := filepath.Join(dir, "results.txt")
+	stdout = captureStdout(t, func() {
+		err = writeResults(TextReporter{}, report, updates, Config{Diff: true, OutputFile: outputFile})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != expectedDiff.String() {
+		t.Errorf("Should be only diff %s, but is %s", expectedDiff.String(), stdout)
+	}
+	if content, _ := ioutil.ReadFile(outputFile); string(content) != expectedReport.String() {
+		t.Errorf("Should be %s, but is %s", expectedReport.String(), content)
+	}
+}
+
+func TestUnifiedDiffSplitsDistantChanges(t *testing.T) {
+	before := []string{"a1", "2", "3", "4", "5", "6", "7", "8", "9", "a10"}
+	after := []string{"b1", "2", "3", "4", "5", "6", "7", "8", "9", "b10"}
+
+	expected := `--- a/file
++++ b/file
+@@ -1,4 +1,4 @@
+-a1
++b1
+ 2
+ 3
+ 4
+@@ -7,4 +7,4 @@
+ 7
+ 8
+ 9
+-a10
++b10
+`
+	if diff := unifiedDiff("file", before, after); diff != expected {
+		t.Errorf("Should be %s, but is %s", expected, diff)
+	}
+}
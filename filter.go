@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"path"
+	"strings"
+)
+
+const (
+	FilterName           = "name"
+	FilterLabel          = "label"
+	FilterComposeProject = "compose-project"
+	FilterNetwork        = "network"
+
+	composeProjectLabel = "com.docker.compose.project"
+)
+
+var filterKinds = []string{FilterName, FilterLabel, FilterComposeProject, FilterNetwork}
+
+// ContainerFilters selects containers to check. A container is checked when it matches every kind of given
+// filters and none of the exclusions. Filters of the same kind match any of their values, except labels which
+// all have to match, like in Docker.
+type ContainerFilters struct {
+	include map[string][]string
+	exclude map[string][]string
+}
+
+// ParseContainerFilters parses filters and exclusions given as kind=value, e.g. label=team=payments.
+func ParseContainerFilters(include []string, exclude []string) (ContainerFilters, error) {
+	var containerFilters ContainerFilters
+	var err error
+
+	containerFilters.include, err = parseFilters(include)
+	if err != nil {
+		return containerFilters, err
+	}
+
+	containerFilters.exclude, err = parseFilters(exclude)
+	return containerFilters, err
+}
+
+func parseFilters(expressions []string) (map[string][]string, error) {
+	parsed := make(map[string][]string)
+
+	for _, expression := range expressions {
+		split := strings.SplitN(expression, "=", 2)
+		if len(split) != 2 || split[1] == "" {
+			return nil, fmt.Errorf("%s is invalid filter, expected kind=value", expression)
+		}
+		kind, value := split[0], split[1]
+
+		if !isFilterKind(kind) {
+			return nil, fmt.Errorf("%s is invalid filter kind, expected one of %s", kind, strings.Join(filterKinds, ", "))
+		}
+		if kind == FilterName {
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("invalid name pattern %s, %v", value, err)
+			}
+		}
+
+		parsed[kind] = append(parsed[kind], value)
+	}
+
+	return parsed, nil
+}
+
+func isFilterKind(kind string) bool {
+	for _, filterKind := range filterKinds {
+		if kind == filterKind {
+			return true
+		}
+	}
+	return false
+}
+
+// Args returns filters which can be evaluated by Docker API when listing containers. Name patterns and
+// exclusions are evaluated only by Filter.
+func (cf ContainerFilters) Args() filters.Args {
+	args := filters.NewArgs()
+
+	for _, label := range cf.include[FilterLabel] {
+		args.Add("label", label)
+	}
+	// Docker requires all labels to match, so only a single project can be filtered by API.
+	if projects := cf.include[FilterComposeProject]; len(projects) == 1 {
+		args.Add("label", composeProjectLabel+"="+projects[0])
+	}
+	for _, network := range cf.include[FilterNetwork] {
+		args.Add("network", network)
+	}
+
+	return args
+}
+
+// usesNetworks tells whether networks of containers are needed to filter them.
+func (cf ContainerFilters) usesNetworks() bool {
+	return len(cf.include[FilterNetwork]) > 0 || len(cf.exclude[FilterNetwork]) > 0
+}
+
+// Filter returns containers which should be checked.
+func (cf ContainerFilters) Filter(containers []types.Container) []types.Container {
+	var filtered []types.Container
+	for _, container := range containers {
+		if cf.Matches(container) {
+			filtered = append(filtered, container)
+		}
+	}
+	return filtered
+}
+
+func (cf ContainerFilters) Matches(container types.Container) bool {
+	for kind, values := range cf.include {
+		if !matchesFilter(container, kind, values) {
+			return false
+		}
+	}
+
+	for kind, values := range cf.exclude {
+		for _, value := range values {
+			if matchesFilter(container, kind, []string{value}) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// matchesFilter tells whether the container matches any of given values, or all of them for labels.
+func matchesFilter(container types.Container, kind string, values []string) bool {
+	if kind == FilterLabel {
+		for _, label := range values {
+			if !hasLabel(container, label) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, value := range values {
+		switch kind {
+		case FilterName:
+			if matched, _ := path.Match(value, containerName(container)); matched {
+				return true
+			}
+		case FilterComposeProject:
+			if container.Labels[composeProjectLabel] == value {
+				return true
+			}
+		case FilterNetwork:
+			if container.NetworkSettings != nil && container.NetworkSettings.Networks[value] != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasLabel tells whether the container has label given as key or key=value.
+func hasLabel(container types.Container, label string) bool {
+	split := strings.SplitN(label, "=", 2)
+
+	value, present := container.Labels[split[0]]
+	if len(split) == 1 {
+		return present
+	}
+	return present && value == split[1]
+}
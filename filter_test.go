@@ -0,0 +1,84 @@
+package main
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"reflect"
+	"testing"
+)
+
+func newTestContainer(name string, labels map[string]string, networks ...string) types.Container {
+	container := types.Container{Names: []string{"/" + name}, Labels: labels, NetworkSettings: &types.SummaryNetworkSettings{Networks: map[string]*network.EndpointSettings{}}}
+	for _, networkName := range networks {
+		container.NetworkSettings.Networks[networkName] = &network.EndpointSettings{}
+	}
+	return container
+}
+
+var testFilterContainers = []types.Container{
+	newTestContainer("shop-api-1", map[string]string{composeProjectLabel: "shop", "team": "payments"}, "shop_default"),
+	newTestContainer("shop-db-1", map[string]string{composeProjectLabel: "shop", "team": "payments", "backup": "daily"}, "shop_default"),
+	newTestContainer("cart-api-1", map[string]string{composeProjectLabel: "cart", "team": "checkout"}, "cart_default", "shop_default"),
+	newTestContainer("traefik", nil, "bridge"),
+}
+
+func TestContainerFilters(t *testing.T) {
+	cases := []struct {
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{nil, nil, []string{"shop-api-1", "shop-db-1", "cart-api-1", "traefik"}},
+		{[]string{"label=team=payments"}, nil, []string{"shop-api-1", "shop-db-1"}},
+		{[]string{"label=team=payments", "label=backup"}, nil, []string{"shop-db-1"}},
+		{[]string{"name=*-api-*"}, nil, []string{"shop-api-1", "cart-api-1"}},
+		{[]string{"compose-project=shop", "compose-project=cart"}, []string{"name=*-db-*"}, []string{"shop-api-1", "cart-api-1"}},
+		{[]string{"network=shop_default", "name=*-api-*"}, nil, []string{"shop-api-1", "cart-api-1"}},
+		{nil, []string{"compose-project=shop", "label=team=checkout"}, []string{"traefik"}},
+	}
+
+	for _, c := range cases {
+		containerFilters, err := ParseContainerFilters(c.include, c.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, container := range containerFilters.Filter(testFilterContainers) {
+			names = append(names, containerName(container))
+		}
+
+		if !reflect.DeepEqual(c.expected, names) {
+			t.Errorf("%v excluding %v should be %v, but is %v", c.include, c.exclude, c.expected, names)
+		}
+	}
+}
+
+func TestContainerFiltersArgs(t *testing.T) {
+	containerFilters, _ := ParseContainerFilters([]string{"label=team=payments", "compose-project=shop", "name=api-*", "network=shop_default"}, []string{"label=backup"})
+
+	args := containerFilters.Args()
+
+	expected := map[string][]string{
+		"label":   {"com.docker.compose.project=shop", "team=payments"},
+		"network": {"shop_default"},
+	}
+	for field, values := range expected {
+		for _, value := range values {
+			if !args.ExactMatch(field, value) {
+				t.Errorf("Should filter %s by %s, but is %v", field, value, args)
+			}
+		}
+	}
+	if args.Len() != len(expected) || args.Include("name") {
+		t.Errorf("Should filter only labels and networks, but is %v", args)
+	}
+}
+
+func TestParseContainerFiltersFailsOnInvalidFilter(t *testing.T) {
+	for _, expression := range []string{"team=payments", "label", "label=", "name=[api"} {
+		if _, err := ParseContainerFilters([]string{expression}, nil); err == nil {
+			t.Errorf("Should fail for %s", expression)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/tlsconfig"
+	"golang.org/x/net/context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultDockerContext = "default"
+
+// HostConfig describes a Docker host given by its address, e.g. tcp://host:2376, unix:///var/run/docker.sock or
+// ssh://user@host, or by name of a Docker context. Addresses like containerd:///run/containerd/containerd.sock
+// describe hosts running bare containerd.
+type HostConfig struct {
+	Name      string
+	Host      string
+	Context   string
+	CertPath  string `mapstructure:"cert-path"`
+	TlsVerify bool   `mapstructure:"tls-verify"`
+	// Namespaces limits containerd namespaces to check, all are checked by default.
+	Namespaces []string
+}
+
+// DockerHost is a host to check containers of. Name labels results when multiple hosts are checked, it is empty
+// for the single host configured by environment. Hosts running containerd have no Docker client.
+type DockerHost struct {
+	Name    string
+	cli     *client.Client
+	runtime ContainerRuntime
+}
+
+func newDockerApiHost(name string, cli *client.Client) DockerHost {
+	return DockerHost{Name: name, cli: cli, runtime: DockerRuntime{cli: cli}}
+}
+
+// NewDockerHosts connects to hosts from the config, or to the host configured by environment when none is given.
+func NewDockerHosts(config Config) ([]DockerHost, error) {
+	hostConfigs := config.Hosts
+	for _, host := range config.DockerHosts {
+		if strings.Contains(host, "://") {
+			hostConfigs = append(hostConfigs, HostConfig{Host: host})
+		} else {
+			hostConfigs = append(hostConfigs, HostConfig{Context: host})
+		}
+	}
+
+	if len(hostConfigs) == 0 {
+		cli, err := newEnvClient()
+		if err != nil {
+			return nil, err
+		}
+		return []DockerHost{newDockerApiHost("", cli)}, nil
+	}
+
+	var hosts []DockerHost
+	names := make(map[string]bool)
+	for _, hostConfig := range hostConfigs {
+		host, err := newDockerHost(hostConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to Docker host %s, %v", hostConfig.displayName(), err)
+		}
+
+		if names[host.Name] {
+			return nil, fmt.Errorf("%s is duplicated Docker host name", host.Name)
+		}
+		names[host.Name] = true
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
+
+func (hc HostConfig) displayName() string {
+	if hc.Name != "" {
+		return hc.Name
+	}
+	if hc.Context != "" {
+		return hc.Context
+	}
+	return hc.Host
+}
+
+func newDockerHost(hostConfig HostConfig) (DockerHost, error) {
+	if hostConfig.Context != "" {
+		var err error
+		hostConfig, err = readDockerContext(hostConfig)
+		if err != nil {
+			return DockerHost{}, err
+		}
+	}
+
+	name, err := hostConfig.name()
+	if err != nil {
+		return DockerHost{}, err
+	}
+
+	if strings.HasPrefix(hostConfig.Host, containerdScheme) {
+		runtime, err := NewContainerdRuntime(strings.TrimPrefix(hostConfig.Host, containerdScheme), hostConfig.Namespaces)
+		if err != nil {
+			return DockerHost{}, err
+		}
+		return DockerHost{Name: name, runtime: runtime}, nil
+	}
+
+	cli, err := newHostClient(hostConfig)
+	if err != nil {
+		return DockerHost{}, err
+	}
+
+	return newDockerApiHost(name, cli), nil
+}
+
+// name returns the configured name, name of the context or the host name of the address.
+func (hc HostConfig) name() (string, error) {
+	if hc.Name != "" {
+		return hc.Name, nil
+	}
+	if hc.Context != "" {
+		return hc.Context, nil
+	}
+
+	hostUrl, err := url.Parse(hc.Host)
+	if err != nil {
+		return "", err
+	}
+	if hostUrl.Hostname() != "" {
+		return hostUrl.Hostname(), nil
+	}
+	return hostUrl.Path, nil
+}
+
+func newHostClient(hostConfig HostConfig) (*client.Client, error) {
+	version := dockerApiVersion()
+
+	if strings.HasPrefix(hostConfig.Host, "ssh://") {
+		dial, err := sshDialer(hostConfig.Host)
+		if err != nil {
+			return nil, err
+		}
+		// Requests are sent through the SSH connection, so the address only has to be valid.
+		httpClient := &http.Client{Transport: &http.Transport{DialContext: dial}}
+		return client.NewClient("tcp://docker", version, httpClient, nil)
+	}
+
+	var httpClient *http.Client
+	if hostConfig.CertPath != "" {
+		tlsConfig, err := tlsconfig.Client(tlsconfig.Options{
+			CAFile:             filepath.Join(hostConfig.CertPath, "ca.pem"),
+			CertFile:           filepath.Join(hostConfig.CertPath, "cert.pem"),
+			KeyFile:            filepath.Join(hostConfig.CertPath, "key.pem"),
+			InsecureSkipVerify: !hostConfig.TlsVerify,
+		})
+		if err != nil {
+			return nil, err
+		}
+		httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	}
+
+	return client.NewClient(hostConfig.Host, version, httpClient, nil)
+}
+
+type dockerContextMeta struct {
+	Endpoints map[string]struct {
+		Host          string
+		SkipTLSVerify bool
+	}
+}
+
+// readDockerContext resolves address and TLS certificates of a context created by docker context create.
+func readDockerContext(hostConfig HostConfig) (HostConfig, error) {
+	if hostConfig.Context == defaultDockerContext {
+		hostConfig.Host = os.Getenv("DOCKER_HOST")
+		if hostConfig.Host == "" {
+			hostConfig.Host = client.DefaultDockerHost
+		}
+		return hostConfig, nil
+	}
+
+	hash := sha256.Sum256([]byte(hostConfig.Context))
+	id := hex.EncodeToString(hash[:])
+	contextsDir := filepath.Join(dockerConfigDir(), "contexts")
+
+	bytes, err := ioutil.ReadFile(filepath.Join(contextsDir, "meta", id, "meta.json"))
+	if err != nil {
+		return hostConfig, fmt.Errorf("failed to read context %s, %v", hostConfig.Context, err)
+	}
+
+	var meta dockerContextMeta
+	err = json.Unmarshal(bytes, &meta)
+	if err != nil {
+		return hostConfig, fmt.Errorf("failed to parse context %s, %v", hostConfig.Context, err)
+	}
+
+	endpoint, present := meta.Endpoints["docker"]
+	if !present || endpoint.Host == "" {
+		return hostConfig, fmt.Errorf("context %s has no Docker endpoint", hostConfig.Context)
+	}
+	hostConfig.Host = endpoint.Host
+
+	certPath := filepath.Join(contextsDir, "tls", id, "docker")
+	if _, err := os.Stat(certPath); err == nil {
+		hostConfig.CertPath = certPath
+		hostConfig.TlsVerify = !endpoint.SkipTLSVerify
+	}
+
+	return hostConfig, nil
+}
+
+func dockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".docker")
+}
+
+// sshDialer connects to Docker daemon on remote host by running docker system dial-stdio over ssh, like
+// Docker CLI does.
+func sshDialer(host string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	args, err := sshArgs(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		cmd := exec.Command("ssh", args...)
+		cmd.Stderr = os.Stderr
+
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+
+		err = cmd.Start()
+		if err != nil {
+			return nil, err
+		}
+
+		return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+	}, nil
+}
+
+func sshArgs(host string) ([]string, error) {
+	hostUrl, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	if hostUrl.Hostname() == "" || (hostUrl.Path != "" && hostUrl.Path != "/") {
+		return nil, fmt.Errorf("%s is invalid ssh host, expected ssh://[user@]host[:port]", host)
+	}
+
+	var args []string
+	if hostUrl.Port() != "" {
+		args = append(args, "-p", hostUrl.Port())
+	}
+
+	destination := hostUrl.Hostname()
+	if hostUrl.User != nil {
+		destination = hostUrl.User.Username() + "@" + destination
+	}
+
+	return append(args, "--", destination, "docker", "system", "dial-stdio"), nil
+}
+
+// commandConn is a connection to standard input and output of a command.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (c *commandConn) Read(b []byte) (int, error) {
+	return c.stdout.Read(b)
+}
+
+func (c *commandConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+	// The command is killed, so its exit status is not interesting.
+	c.cmd.Wait()
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return commandAddr{}
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return commandAddr{}
+}
+
+func (c *commandConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string {
+	return "command"
+}
+
+func (commandAddr) String() string {
+	return "command"
+}
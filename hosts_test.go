@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHostConfigName(t *testing.T) {
+	cases := map[string]HostConfig{
+		"web01":                {Host: "tcp://web01:2376"},
+		"10.0.0.5":             {Host: "ssh://deploy@10.0.0.5:2222"},
+		"/var/run/docker.sock": {Host: "unix:///var/run/docker.sock"},
+		"production":           {Host: "ssh://deploy@web01", Context: "production"},
+		"api":                  {Name: "api", Host: "tcp://web01:2376"},
+	}
+
+	for expected, hostConfig := range cases {
+		name, err := hostConfig.name()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != expected {
+			t.Errorf("Should be %s, but is %s", expected, name)
+		}
+	}
+}
+
+func TestSshArgs(t *testing.T) {
+	args, err := sshArgs("ssh://deploy@web01:2222")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"-p", "2222", "--", "deploy@web01", "docker", "system", "dial-stdio"}
+	if !reflect.DeepEqual(expected, args) {
+		t.Errorf("Should be %v, but is %v", expected, args)
+	}
+
+	if _, err := sshArgs("ssh://web01/var/run/docker.sock"); err == nil {
+		t.Error("Should fail for ssh host with path")
+	}
+}
+
+func TestReadDockerContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvchk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Contexts are stored in directories named by SHA-256 of their names.
+	id := "ab8e18ef4ebebeddc0b3152ce9c9006e14fc05242e3fc9ce32246ea6a9543074"
+	metaDir := filepath.Join(dir, "contexts", "meta", id)
+	tlsDir := filepath.Join(dir, "contexts", "tls", id, "docker")
+	for _, contextDir := range []string{metaDir, tlsDir} {
+		if err := os.MkdirAll(contextDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	meta := `{"Name":"production","Endpoints":{"docker":{"Host":"tcp://web01:2376","SkipTLSVerify":false}}}`
+	if err := ioutil.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("DOCKER_CONFIG", dir)
+	defer os.Unsetenv("DOCKER_CONFIG")
+
+	hostConfig, err := readDockerContext(HostConfig{Context: "production"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := HostConfig{Context: "production", Host: "tcp://web01:2376", CertPath: tlsDir, TlsVerify: true}
+	if !reflect.DeepEqual(expected, hostConfig) {
+		t.Errorf("Should be %+v, but is %+v", expected, hostConfig)
+	}
+
+	if _, err := readDockerContext(HostConfig{Context: "staging"}); err == nil {
+		t.Error("Should fail for missing context")
+	}
+}
+
+func TestNewDockerHostsFailsOnDuplicatedNames(t *testing.T) {
+	_, err := NewDockerHosts(Config{DockerHosts: []string{"tcp://web01:2375", "tcp://web01:2376"}})
+	if err == nil {
+		t.Error("Should fail for duplicated host names")
+	}
+}
+
+func TestNewDockerHostsCreatesContainerdRuntime(t *testing.T) {
+	hosts, err := NewDockerHosts(Config{Hosts: []HostConfig{{Name: "edge", Host: "containerd:///run/k3s/containerd/containerd.sock", Namespaces: []string{"k8s.io"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runtime, ok := hosts[0].runtime.(ContainerdRuntime)
+	if !ok || hosts[0].cli != nil {
+		t.Fatalf("Should be containerd host without Docker client, but is %+v", hosts[0])
+	}
+	if runtime.address != "/run/k3s/containerd/containerd.sock" || !reflect.DeepEqual([]string{"k8s.io"}, runtime.namespaces) {
+		t.Errorf("Should use given address and namespaces, but is %+v", runtime)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{"join": strings.Join}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>dvchk report {{ .CheckedAt.Format "2006-01-02" }}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.4em 1em; text-align: left; vertical-align: top; }
+th.sortable { cursor: pointer; user-select: none; }
+th.sortable::after { content: " \2195"; color: #aaa; }
+code { font-size: 0.95em; }
+.badge { border-radius: 0.8em; color: #fff; font-size: 0.85em; padding: 0.1em 0.6em; }
+.badge.major { background: #c0392b; } .badge.minor { background: #d35400; }
+.badge.patch { background: #2980b9; } .badge.none { background: #27ae60; }
+.summary span { margin-right: 2em; }
+</style>
+</head>
+<body>
+<h1>dvchk report</h1>
+<p>Checked at {{ .CheckedAt.Format "2006-01-02 15:04:05 MST" }}</p>
+<p class="summary">{{ with .Summary }}<span>Images: {{ .Total }}</span><span>Outdated: {{ .Outdated }}</span><span>Up to date: {{ .UpToDate }}</span><span>Skipped: {{ .Skipped }}</span><span>Unauthorized: {{ .Unauthorized }}</span><span>Failed: {{ .Errors }}</span>{{ end }}</p>
+
+<h2>Images</h2>
+<table class="sortable">
+<thead>
+<tr><th class="sortable">Container</th><th class="sortable">Image</th><th class="sortable">Current</th><th class="sortable">Level</th><th class="sortable">Patch</th><th class="sortable">Minor</th><th class="sortable">Major</th></tr>
+</thead>
+<tbody>
+{{ range .Images }}<tr>
+<td>{{ .ContainerKey }}{{ range .Sources }}<div>{{ .Path }}:{{ .Line }}</div>{{ end }}</td>
+<td><code>{{ .Image }}</code></td>
+<td><code>{{ .Current }}</code></td>
+<td data-sort="{{ .Level }}"><span class="badge {{ .Level }}">{{ if eq .Level "none" }}up to date{{ else }}{{ .Level }}{{ end }}</span></td>
+<td><code>{{ .LatestPatch }}</code></td>
+<td><code>{{ .LatestMinor }}</code></td>
+<td><code>{{ .LatestMajor }}</code></td>
+</tr>
+{{ end }}</tbody>
+</table>
+{{ if .Failures }}
+<h2>Skipped and failed images</h2>
+<table class="sortable">
+<thead>
+<tr><th class="sortable">Container</th><th class="sortable">Image</th><th class="sortable">State</th><th class="sortable">Reason</th><th>Error</th></tr>
+</thead>
+<tbody>
+{{ range .Failures }}<tr><td>{{ .ContainerKey }}</td><td><code>{{ .Image }}</code></td><td>{{ .State }}</td><td>{{ .Reason }}</td><td>{{ .Error }}</td></tr>
+{{ end }}</tbody>
+</table>
+{{ end }}
+<script>
+(function () {
+  var levels = { none: 0, patch: 1, minor: 2, major: 3 };
+  function value(row, column) {
+    var cell = row.cells[column];
+    var sort = cell.getAttribute("data-sort");
+    return sort in levels ? String(levels[sort]) : cell.textContent.trim();
+  }
+  document.querySelectorAll("table.sortable").forEach(function (table) {
+    table.querySelectorAll("th.sortable").forEach(function (header, column) {
+      var ascending = true;
+      header.addEventListener("click", function () {
+        var body = table.tBodies[0];
+        var rows = Array.prototype.slice.call(body.rows);
+        rows.sort(function (a, b) {
+          var result = value(a, column).localeCompare(value(b, column), undefined, { numeric: true });
+          return ascending ? result : -result;
+        });
+        rows.forEach(function (row) { body.appendChild(row); });
+        ascending = !ascending;
+      });
+    });
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+// HtmlReporter prints a single static HTML page with sortable tables of images and of images that were not checked.
+type HtmlReporter struct{}
+
+func (HtmlReporter) Report(w io.Writer, report Report) error {
+	return htmlReportTemplate.Execute(w, report)
+}
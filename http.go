@@ -1,44 +1,103 @@
 package main
 
 import (
-	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 )
 
 const (
-	v2RegistryFormat = "https://%s/v2/"
-	tagsListFormat   = v2RegistryFormat + "%s/%s/tags/list"
+	v2RegistryFormat = "%s://%s/v2/"
+	tagsListFormat   = v2RegistryFormat + "%s/tags/list"
+	manifestFormat   = v2RegistryFormat + "%s/manifests/%s"
+	blobFormat       = v2RegistryFormat + "%s/blobs/%s"
+
+	schemeHttps = "https"
+	schemeHttp  = "http"
+
+	retryBaseDelay     = 500 * time.Millisecond
+	maxRetryDelay      = 30 * time.Second
+	maxRetryAfterDelay = 60 * time.Second
 )
 
 type ApiClient struct {
-	http *http.Client
+	http               *http.Client
+	retries            int
+	baseDelay          time.Duration
+	rateLimits         *RateLimits
+	metrics            *Metrics
+	insecureRegistries *InsecureRegistries
+	plainHttp          *sync.Map
 }
 
-func NewApiClient(config Config) *ApiClient {
+func NewApiClient(config Config, rateLimits *RateLimits, metrics *Metrics) (*ApiClient, error) {
+	insecureRegistries, err := NewInsecureRegistries(config.InsecureRegistries)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := NewRegistryTransport(config, insecureRegistries)
+	if err != nil {
+		return nil, err
+	}
+
 	httpClient := http.Client{
 		Timeout:   time.Duration(time.Duration(config.Timeout) * time.Second),
-		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: config.Insecure}},
+		Transport: transport,
 	}
 
-	return &ApiClient{http: &httpClient}
+	return &ApiClient{
+		http:               &httpClient,
+		retries:            config.Retries,
+		baseDelay:          retryBaseDelay,
+		rateLimits:         rateLimits,
+		metrics:            metrics,
+		insecureRegistries: insecureRegistries,
+		plainHttp:          &sync.Map{},
+	}, nil
 }
 
+// GetV2 checks the registry API version. Insecure registries that cannot be reached over HTTPS
+// are switched to plain HTTP for all subsequent requests, the same way Docker daemon does it.
 func (ac ApiClient) GetV2(registry string) (*http.Response, error) {
-	url := fmt.Sprintf(v2RegistryFormat, registry)
+	if ac.insecureRegistries.Contains(registry) {
+		ac.detectPlainHttp(registry)
+	}
 
-	return ac.http.Get(url)
+	return ac.get(ac.createV2Url(registry))
+}
+
+func (ac ApiClient) detectPlainHttp(registry string) {
+	response, err := ac.http.Get(fmt.Sprintf(v2RegistryFormat, schemeHttps, registry))
+	if err == nil {
+		discardBody(response)
+		return
+	}
+
+	log.Debugf("Failed to reach insecure registry %s over HTTPS, falling back to HTTP, %v\n", registry, err)
+	ac.UsePlainHttp(registry)
+}
+
+func (ac ApiClient) UsePlainHttp(registry string) {
+	ac.plainHttp.Store(registry, true)
 }
 
 func (ac ApiClient) GetTagList(i Image) (*http.Response, error) {
-	tagsListUrl := createTagsListUrl(i)
+	tagsListUrl := ac.createTagsListUrl(i)
 
-	return ac.http.Get(tagsListUrl)
+	return ac.get(tagsListUrl)
 }
 
 func (ac ApiClient) GetTagListAuthenticated(i Image, token string) (*http.Response, error) {
-	tagsListUrl := createTagsListUrl(i)
+	tagsListUrl := ac.createTagsListUrl(i)
 
 	request, err := http.NewRequest("GET", tagsListUrl, nil)
 	if err != nil {
@@ -47,7 +106,42 @@ func (ac ApiClient) GetTagListAuthenticated(i Image, token string) (*http.Respon
 
 	request.Header.Add("Authorization", token)
 
-	return ac.http.Do(request)
+	return ac.do(request)
+}
+
+// GetManifest downloads manifest of the image by tag or digest, accepting both OCI and Docker
+// media types of image indexes and manifests.
+func (ac ApiClient) GetManifest(i Image, reference string, token string) (*http.Response, error) {
+	manifestUrl := fmt.Sprintf(manifestFormat, ac.scheme(i.Registry), i.Registry, i.Repository(), reference)
+
+	request, err := http.NewRequest("GET", manifestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mediaType := range manifestMediaTypes {
+		request.Header.Add("Accept", mediaType)
+	}
+	if token != "" {
+		request.Header.Add("Authorization", token)
+	}
+
+	return ac.do(request)
+}
+
+func (ac ApiClient) GetBlob(i Image, digest string, token string) (*http.Response, error) {
+	blobUrl := fmt.Sprintf(blobFormat, ac.scheme(i.Registry), i.Registry, i.Repository(), digest)
+
+	request, err := http.NewRequest("GET", blobUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		request.Header.Add("Authorization", token)
+	}
+
+	return ac.do(request)
 }
 
 func (ac ApiClient) GetToken(authUrl AuthUrl) (*http.Response, error) {
@@ -56,7 +150,7 @@ func (ac ApiClient) GetToken(authUrl AuthUrl) (*http.Response, error) {
 		return nil, err
 	}
 
-	return ac.http.Do(request)
+	return ac.do(request)
 }
 
 func (ac ApiClient) GetTokenWithCredentials(authUrl AuthUrl, cr Credentials) (*http.Response, error) {
@@ -67,11 +161,150 @@ func (ac ApiClient) GetTokenWithCredentials(authUrl AuthUrl, cr Credentials) (*h
 
 	request.SetBasicAuth(cr.Username, cr.Password)
 
-	return ac.http.Do(request)
+	return ac.do(request)
+}
+
+func (ac ApiClient) get(url string) (*http.Response, error) {
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ac.do(request)
+}
+
+// do sends the request, retrying idempotent requests on network errors, server errors
+// and rate limiting with exponential backoff.
+func (ac ApiClient) do(request *http.Request) (*http.Response, error) {
+	registry := request.URL.Host
+
+	for attempt := 0; ; attempt++ {
+		ac.rateLimits.Wait(registry)
+
+		start := time.Now()
+		response, err := ac.http.Do(request)
+		ac.metrics.ObserveRequest(registry, response, time.Since(start))
+		if err == nil {
+			ac.rateLimits.Update(registry, response.Header)
+		}
+
+		if attempt >= ac.retries || !isRetryable(request, response, err) {
+			return response, err
+		}
+
+		delay, ok := ac.retryDelay(response, attempt)
+		if !ok {
+			return response, err
+		}
+
+		if response != nil {
+			discardBody(response)
+			log.Debugf("Request to %s failed with status code %d, retrying in %v\n", request.URL, response.StatusCode, delay)
+		} else {
+			log.Debugf("Request to %s failed, retrying in %v, %v\n", request.URL, delay, err)
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+func isRetryable(request *http.Request, response *http.Response, err error) bool {
+	if request.Method != "GET" {
+		return false
+	}
+
+	if err != nil {
+		return !isCertificateError(err)
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func isCertificateError(err error) bool {
+	for err != nil {
+		switch specificError := err.(type) {
+		case x509.UnknownAuthorityError, x509.CertificateInvalidError, x509.HostnameError, x509.InsecureAlgorithmError:
+			return true
+		case *url.Error:
+			err = specificError.Err
+		case interface{ Unwrap() error }:
+			err = specificError.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before the next attempt. Retry-After sent by the registry takes
+// precedence, unless it exceeds the maximum delay, in which case the request is not retried.
+func (ac ApiClient) retryDelay(response *http.Response, attempt int) (time.Duration, bool) {
+	if response != nil {
+		retryAfter, present := parseRetryAfter(response.Header.Get("Retry-After"))
+		if present {
+			return retryAfter, retryAfter <= maxRetryAfterDelay
+		}
+	}
+
+	backoff := ac.baseDelay << uint(attempt)
+	if backoff > maxRetryDelay || backoff <= 0 {
+		backoff = maxRetryDelay
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+
+	return backoff/2 + jitter, true
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	delay := time.Until(date)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
+
+func discardBody(response *http.Response) {
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+	_ = response.Body.Close()
+}
+
+func (ac ApiClient) scheme(registry string) string {
+	if _, present := ac.plainHttp.Load(registry); present {
+		return schemeHttp
+	}
+	return schemeHttps
+}
+
+func (ac ApiClient) createV2Url(registry string) string {
+	return fmt.Sprintf(v2RegistryFormat, ac.scheme(registry), registry)
 }
 
-func createTagsListUrl(i Image) string {
-	return fmt.Sprintf(tagsListFormat, i.Registry, i.Author, i.Name)
+func (ac ApiClient) createTagsListUrl(i Image) string {
+	return fmt.Sprintf(tagsListFormat, ac.scheme(i.Registry), i.Registry, i.Repository())
 }
 
 func createTokenRequest(authUrl AuthUrl) (*http.Request, error) {
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApiClientRetriesServerErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("RateLimit-Limit", "100;w=21600")
+		w.Header().Set("RateLimit-Remaining", "76;w=21600")
+	}))
+	defer server.Close()
+
+	rateLimits := NewRateLimits()
+	apiClient, _ := NewApiClient(Config{Retries: 3, Timeout: 5}, rateLimits, nil)
+	apiClient.baseDelay = time.Millisecond
+
+	response, err := apiClient.get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusOK || requests != 3 {
+		t.Errorf("Should succeed after 3 requests, but got status %d after %d requests", response.StatusCode, requests)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	limit := rateLimits.limits[host]
+	if limit == nil {
+		t.Fatalf("Should track rate limit of %s", host)
+	}
+	expected := RateLimit{Limit: 100, Remaining: 76, Window: 6 * time.Hour, Reset: limit.Reset}
+	if *limit != expected {
+		t.Errorf("Should be %v, but is %v", expected, *limit)
+	}
+	if limit.Reset.IsZero() {
+		t.Error("Should know when requests are replenished")
+	}
+}
+
+func TestApiClientGivesUpOnLongRetryAfter(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	apiClient, _ := NewApiClient(Config{Retries: 3, Timeout: 5}, NewRateLimits(), nil)
+
+	response, err := apiClient.get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusTooManyRequests || requests != 1 {
+		t.Errorf("Should give up after 1 request, but got status %d after %d requests", response.StatusCode, requests)
+	}
+}
+
+func TestRateLimitsThrottleDelay(t *testing.T) {
+	rateLimits := NewRateLimits()
+
+	header := http.Header{}
+	header.Set("RateLimit-Limit", "100;w=21600")
+	header.Set("RateLimit-Remaining", "50;w=21600")
+	rateLimits.Update("registry.com", header)
+
+	if delay := rateLimits.throttleDelay("registry.com"); delay != 0 {
+		t.Errorf("Should not throttle, but delay is %v", delay)
+	}
+
+	header.Set("RateLimit-Remaining", "8;w=21600")
+	rateLimits.Update("registry.com", header)
+
+	if delay := rateLimits.throttleDelay("registry.com"); delay != 3*throttleStep {
+		t.Errorf("Should be %v, but is %v", 3*throttleStep, delay)
+	}
+
+	// A single request of 100 per 6 hours is replenished in 216 seconds, which is more than the cap.
+	header.Set("RateLimit-Remaining", "0;w=21600")
+	rateLimits.Update("registry.com", header)
+
+	if delay := rateLimits.throttleDelay("registry.com"); delay != maxThrottleDelay {
+		t.Errorf("Should be %v, but is %v", maxThrottleDelay, delay)
+	}
+
+	header.Set("RateLimit-Reset", "3")
+	rateLimits.Update("registry.com", header)
+
+	if delay := rateLimits.throttleDelay("registry.com"); delay <= 2*time.Second || delay > 3*time.Second {
+		t.Errorf("Should wait until reset in 3s, but delay is %v", delay)
+	}
+
+	rateLimits.limits["registry.com"].Reset = time.Now().Add(-time.Second)
+	if delay := rateLimits.throttleDelay("registry.com"); delay != 0 {
+		t.Errorf("Should not throttle after reset, but delay is %v", delay)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// InsecureRegistries mirrors the insecure-registries setting of the Docker daemon. Entries are either
+// registry hosts or CIDRs matched against the addresses registry hosts resolve to.
+type InsecureRegistries struct {
+	hosts map[string]bool
+	cidrs []*net.IPNet
+
+	mutex    sync.Mutex
+	resolved map[string]bool
+}
+
+func NewInsecureRegistries(entries []string) (*InsecureRegistries, error) {
+	ir := &InsecureRegistries{hosts: make(map[string]bool), resolved: make(map[string]bool)}
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "http://"), "https://")
+		entry = strings.TrimSuffix(entry, "/")
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			_, cidr, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid insecure registry %s, %v", entry, err)
+			}
+			ir.cidrs = append(ir.cidrs, cidr)
+			continue
+		}
+
+		ir.hosts[entry] = true
+	}
+
+	return ir, nil
+}
+
+func (ir *InsecureRegistries) Contains(registry string) bool {
+	if ir.hosts[registry] {
+		return true
+	}
+
+	ir.mutex.Lock()
+	defer ir.mutex.Unlock()
+
+	insecure, present := ir.resolved[registry]
+	if !present {
+		insecure = ir.matchesCidr(registry)
+		ir.resolved[registry] = insecure
+	}
+
+	return insecure
+}
+
+func (ir *InsecureRegistries) matchesCidr(registry string) bool {
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		ips, err = net.LookupIP(host)
+		if err != nil {
+			return false
+		}
+	}
+
+	for _, ip := range ips {
+		for _, cidr := range ir.cidrs {
+			if cidr.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
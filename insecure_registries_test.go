@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInsecureRegistriesContains(t *testing.T) {
+	insecureRegistries, err := NewInsecureRegistries([]string{"registry.local:5000", "10.0.0.0/8", "http://plain.local/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"registry.local:5000": true,
+		"registry.local":      false,
+		"plain.local":         true,
+		"10.1.2.3:5000":       true,
+		"192.168.1.1:5000":    false,
+	}
+
+	for registry, expected := range cases {
+		if insecure := insecureRegistries.Contains(registry); insecure != expected {
+			t.Errorf("%s should be insecure: %t, but is %t", registry, expected, insecure)
+		}
+	}
+}
+
+func TestApiClientFallsBackToPlainHttpForInsecureRegistry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	registry := strings.TrimPrefix(server.URL, "http://")
+
+	apiClient, err := NewApiClient(Config{InsecureRegistries: []string{"127.0.0.0/8"}, Timeout: 5}, NewRateLimits(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := apiClient.GetV2(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Should be %d, but is %d", http.StatusOK, response.StatusCode)
+	}
+
+	image := Image{Registry: registry, Name: "image"}
+	expected := server.URL + "/v2/image/tags/list"
+	if url := apiClient.createTagsListUrl(image); url != expected {
+		t.Errorf("Should be %s, but is %s", expected, url)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JunitReporter prints a testcase per image, failing when the image has updates above the allowed level. Images that
+// could not be checked are errors, or skipped when their tags are not versions.
+type JunitReporter struct {
+	allowed UpdateLevel
+}
+
+func (jr JunitReporter) Report(w io.Writer, report Report) error {
+	suite := junitTestSuite{Name: "dvchk"}
+
+	for _, image := range report.Images {
+		testCase := junitTestCase{ClassName: junitClassName(image.ContainerKey(), image.Sources), Name: image.Image}
+
+		if image.Exceeds(jr.allowed) {
+			testCase.Failure = &junitMessage{
+				Message: fmt.Sprintf("%s update is available", image.Level),
+				Text:    image.Description,
+			}
+			suite.Failures++
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	for _, failure := range report.Failures {
+		testCase := junitTestCase{ClassName: junitClassName(failure.ContainerKey(), nil), Name: failure.Image}
+
+		message := &junitMessage{Message: failure.Reason, Text: failure.Error}
+		if failure.State == StateSkipped {
+			testCase.Skipped = message
+			suite.Skipped++
+		} else {
+			testCase.Error = message
+			suite.Errors++
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(suite.TestCases)
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+// junitClassName groups testcases by container or by file referencing the image.
+func junitClassName(container string, sources []ReportSource) string {
+	if container != "" {
+		return container
+	}
+
+	var paths []string
+	for _, source := range sources {
+		paths = append(paths, source.Path)
+	}
+	if len(paths) > 0 {
+		return strings.Join(paths, ",")
+	}
+
+	return "images"
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"github.com/docker/docker/api/types"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// podsPageSize limits pods listed by a single request, large clusters are listed in pages.
+const podsPageSize = 500
+
+// KubernetesWorkload is a container of a workload, e.g. of a deployment, running an image. Pods of the workload
+// running the same image are checked once.
+type KubernetesWorkload struct {
+	// Name identifies the container as namespace/kind/workload/container.
+	Name string
+	// Key identifies the container among checked ones. It is the name, followed by @image when pods of the workload
+	// run more than one image of the container, e.g. during a rolling update.
+	Key string
+	// Workload is the name of the workload, which name filters match.
+	Workload string
+	Image    string
+	// Labels are labels of the first pod of the workload.
+	Labels map[string]string
+}
+
+// NewKubernetesClient connects using given kubeconfig, $KUBECONFIG or ~/.kube/config, or using service account
+// of the pod when running in a cluster without kubeconfig. It returns namespace of the current context too, which
+// is empty when the context does not set it.
+func NewKubernetesClient(kubeconfigPath string, timeout time.Duration) (kubernetes.Interface, string, error) {
+	restConfig, namespace, err := kubernetesConfig(kubeconfigPath)
+	if err != nil {
+		return nil, "", err
+	}
+	restConfig.Timeout = timeout
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create Kubernetes client, %v", err)
+	}
+	return clientset, namespace, nil
+}
+
+func kubernetesConfig(kubeconfigPath string) (*rest.Config, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfigPath
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read kubeconfig, %v", err)
+	}
+
+	if kubeconfigPath == "" && len(rawConfig.Contexts) == 0 {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, "", fmt.Errorf("no kubeconfig found and not running in a cluster, %v", err)
+		}
+		return restConfig, "", nil
+	}
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid kubeconfig, %v", err)
+	}
+
+	namespace := ""
+	if currentContext, present := rawConfig.Contexts[rawConfig.CurrentContext]; present {
+		namespace = currentContext.Namespace
+	}
+	return restConfig, namespace, nil
+}
+
+// ListKubernetesWorkloads lists images running in pods of given namespaces, or of all namespaces, grouped by
+// owning workloads.
+func ListKubernetesWorkloads(clientset kubernetes.Interface, namespaces []string, labelSelector string) ([]KubernetesWorkload, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	seen := make(map[string]bool)
+	var workloads []KubernetesWorkload
+	for _, namespace := range namespaces {
+		pods, err := listRunningPods(clientset, namespace, labelSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods {
+			kind, name := podWorkload(pod)
+			for _, status := range pod.Status.ContainerStatuses {
+				workload := KubernetesWorkload{
+					Name:     strings.Join([]string{pod.Namespace, strings.ToLower(kind), name, status.Name}, "/"),
+					Workload: name,
+					Image:    kubernetesImage(containerImage(pod, status)),
+					Labels:   pod.Labels,
+				}
+				log.Debugf("Pod %s/%s runs %s (%s)", pod.Namespace, pod.Name, status.Image, status.ImageID)
+
+				// Pods of a rolling update may run different images of the same container.
+				key := workload.Name + " " + workload.Image
+				if !seen[key] {
+					seen[key] = true
+					workloads = append(workloads, workload)
+				}
+			}
+		}
+	}
+
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].Name != workloads[j].Name {
+			return workloads[i].Name < workloads[j].Name
+		}
+		return workloads[i].Image < workloads[j].Image
+	})
+
+	images := make(map[string]int)
+	for _, workload := range workloads {
+		images[workload.Name]++
+	}
+	for i := range workloads {
+		workloads[i].Key = workloads[i].Name
+		if images[workloads[i].Name] > 1 {
+			workloads[i].Key += "@" + workloads[i].Image
+		}
+	}
+
+	return workloads, nil
+}
+
+func listRunningPods(clientset kubernetes.Interface, namespace string, labelSelector string) ([]corev1.Pod, error) {
+	options := metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: "status.phase=" + string(corev1.PodRunning),
+		Limit:         podsPageSize,
+	}
+
+	var pods []corev1.Pod
+	for {
+		list, err := clientset.CoreV1().Pods(namespace).List(context.Background(), options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods, %v", err)
+		}
+
+		pods = append(pods, list.Items...)
+		if list.Continue == "" {
+			return pods, nil
+		}
+		options.Continue = list.Continue
+	}
+}
+
+// podWorkload returns kind and name of the workload controlling the pod. Replica sets are named by their
+// deployment and hash of the pod template, so deployments are found without asking for replica sets.
+func podWorkload(pod corev1.Pod) (string, string) {
+	if owner := metav1.GetControllerOf(&pod); owner != nil {
+		hash := pod.Labels["pod-template-hash"]
+		if owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+		return owner.Kind, owner.Name
+	}
+
+	return "Pod", pod.Name
+}
+
+// containerImage returns image the container runs. Runtimes report images pulled by digest or matched to local
+// images by ID only as sha256:..., then the image of the pod spec is used, or the image ID without a tag.
+func containerImage(pod corev1.Pod, status corev1.ContainerStatus) string {
+	if !strings.HasPrefix(status.Image, "sha256:") {
+		return status.Image
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if container.Name == status.Name {
+			return container.Image
+		}
+	}
+
+	// Docker reports image IDs like docker-pullable://nginx@sha256:...
+	imageID := status.ImageID
+	if i := strings.Index(imageID, "://"); i >= 0 {
+		imageID = imageID[i+len("://"):]
+	}
+	return imageID
+}
+
+// kubernetesImage returns image reported by the container runtime without digest, shortened like Docker shows it.
+func kubernetesImage(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	return normalizeContainerdImage(image)
+}
+
+// workloadAsContainer describes the workload as a container named by the workload, so that name and label
+// filters apply to workloads too.
+func workloadAsContainer(workload KubernetesWorkload) types.Container {
+	return types.Container{Names: []string{"/" + workload.Workload}, Image: workload.Image, Labels: workload.Labels}
+}
+
+// CheckWorkloadsImageTags checks images of Kubernetes workloads, which are reported in place of containers.
+func (v *VersionChecker) CheckWorkloadsImageTags(workloads []KubernetesWorkload) {
+	for _, workload := range workloads {
+		fmt.Fprintf(os.Stderr, "Checking %s [%s]\n", workload.Image, workload.Key)
+
+		v.checkImageTags(workload.Image, workload.Key, "")
+	}
+
+	fmt.Fprintln(os.Stderr)
+}
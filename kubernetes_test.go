@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestPod(namespace string, name string, hash string, owner string, images ...string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": name}}}
+	pod.Status.Phase = corev1.PodRunning
+	if hash != "" {
+		pod.Labels["pod-template-hash"] = hash
+	}
+	// Owner is given as kind/name.
+	if owner != "" {
+		controller := true
+		split := strings.SplitN(owner, "/", 2)
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: split[0], Name: split[1], Controller: &controller}}
+	}
+	for i, image := range images {
+		containerName := fmt.Sprintf("c%d", i)
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: containerName, Image: image})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{Name: containerName, Image: image})
+	}
+	return pod
+}
+
+func TestListKubernetesWorkloadsGroupsPodsByOwner(t *testing.T) {
+	// Runtimes report images pulled by digest only by their digests.
+	worker := newTestPod("batch", "worker-0", "", "StatefulSet/worker", "author/worker:3.0.0")
+	worker.Status.ContainerStatuses[0].Image = "sha256:e6f1816883972d4be47bd48879a08919b96afcd344132622e4d444987919323c"
+	cron := newTestPod("batch", "cron", "", "", "author/cron:1.0.0")
+	cron.Status.ContainerStatuses[0].Name = "renamed"
+	cron.Status.ContainerStatuses[0].Image = "sha256:7031c1b283388d2c2e09b57badb803c05ebed362dc88d84b480cc47f72a21097"
+	cron.Status.ContainerStatuses[0].ImageID = "docker-pullable://registry.com/author/cron@sha256:7031c1b283388d2c2e09b57badb803c05ebed362dc88d84b480cc47f72a21097"
+
+	clientset := fake.NewSimpleClientset(
+		newTestPod("shop", "api-5d8f7b-x1", "5d8f7b", "ReplicaSet/api-5d8f7b", "docker.io/author/api:1.0.0", "docker.io/library/nginx:1.25.3"),
+		newTestPod("shop", "api-5d8f7b-x2", "5d8f7b", "ReplicaSet/api-5d8f7b", "docker.io/author/api:1.0.0", "docker.io/library/nginx:1.25.3"),
+		newTestPod("shop", "api-6c9a1e-x3", "6c9a1e", "ReplicaSet/api-6c9a1e", "docker.io/author/api:1.1.0"),
+		newTestPod("shop", "db-0", "", "StatefulSet/db", "registry.com/postgres:15.4@sha256:abc"),
+		newTestPod("kube-system", "debug", "", "", "busybox:1.36.1"),
+		worker,
+		cron,
+	)
+
+	workloads, err := ListKubernetesWorkloads(clientset, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, workload := range workloads {
+		names = append(names, workload.Key+"="+workload.Image)
+	}
+	expected := []string{
+		"batch/pod/cron/renamed=registry.com/author/cron",
+		"batch/statefulset/worker/c0=author/worker:3.0.0",
+		"kube-system/pod/debug/c0=busybox:1.36.1",
+		"shop/deployment/api/c0@author/api:1.0.0=author/api:1.0.0",
+		"shop/deployment/api/c0@author/api:1.1.0=author/api:1.1.0",
+		"shop/deployment/api/c1=nginx:1.25.3",
+		"shop/statefulset/db/c0=registry.com/postgres:15.4",
+	}
+	if !reflect.DeepEqual(expected, names) {
+		t.Errorf("Should be %v, but is %v", expected, names)
+	}
+}
+
+func TestListKubernetesWorkloadsListsGivenNamespaces(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		newTestPod("shop", "api", "", "", "author/api:1.0.0"),
+		newTestPod("batch", "report", "", "", "author/report:2.0.0"),
+		newTestPod("kube-system", "debug", "", "", "busybox:1.36.1"),
+	)
+
+	workloads, err := ListKubernetesWorkloads(clientset, []string{"shop", "batch"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(workloads) != 2 || workloads[0].Name != "batch/pod/report/c0" || workloads[1].Name != "shop/pod/api/c0" {
+		t.Errorf("Should be workloads of both namespaces, but is %+v", workloads)
+	}
+
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("pods is forbidden")
+	})
+	_, err = ListKubernetesWorkloads(clientset, []string{"shop"}, "")
+	if err == nil {
+		t.Error("Should fail when pods cannot be listed")
+	}
+}
+
+func TestListKubernetesWorkloadsListsPodsOfAllPages(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	var restrictions []string
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		listRestrictions := action.(k8stesting.ListAction).GetListRestrictions()
+		restrictions = append(restrictions, listRestrictions.Labels.String()+" "+listRestrictions.Fields.String())
+
+		pod := newTestPod("shop", "api-2", "", "", "author/api:1.1.0")
+		list := &corev1.PodList{}
+		if len(restrictions) == 1 {
+			pod = newTestPod("shop", "api-1", "", "", "author/api:1.0.0")
+			list.Continue = "next"
+		}
+		pod.Labels["app"] = "api"
+		list.Items = []corev1.Pod{*pod}
+		return true, list, nil
+	})
+
+	workloads, err := ListKubernetesWorkloads(clientset, []string{"shop"}, "app=api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(workloads) != 2 || workloads[0].Image != "author/api:1.0.0" || workloads[1].Image != "author/api:1.1.0" {
+		t.Errorf("Should be workloads of both pages, but is %+v", workloads)
+	}
+	expected := []string{"app=api status.phase=Running", "app=api status.phase=Running"}
+	if !reflect.DeepEqual(expected, restrictions) {
+		t.Errorf("Should be %v, but is %v", expected, restrictions)
+	}
+}
+
+func TestPipelineReportsEachImageOfRollingUpdate(t *testing.T) {
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/author/api/tags/list" {
+			_, _ = w.Write([]byte(`{"name": "author/api", "tags": ["1.0.0", "1.1.0"]}`))
+		}
+	}))
+	defer mirror.Close()
+
+	config := Config{Timeout: 5, RegistryMirrors: []string{mirror.URL}}
+	pipeline, err := NewPipeline(config, []DockerHost{{}}, NewRateLimits(), NewMetrics(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline.kubernetes = fake.NewSimpleClientset(
+		newTestPod("shop", "api-5d8f7b-x1", "5d8f7b", "ReplicaSet/api-5d8f7b", "author/api:1.0.0"),
+		newTestPod("shop", "api-6c9a1e-x2", "6c9a1e", "ReplicaSet/api-6c9a1e", "author/api:1.1.0"),
+	)
+
+	result, err := pipeline.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"shop/deployment/api/c0@author/api:1.0.0": "author/api:1.0.0",
+		"shop/deployment/api/c0@author/api:1.1.0": "author/api:1.1.0",
+	}
+	if !reflect.DeepEqual(expected, result.Containers) {
+		t.Errorf("Should be %v, but is %v", expected, result.Containers)
+	}
+
+	for _, inv := range result.ImagesNewerVersions {
+		if result.Containers[inv.image.ContainerKey()] != inv.imageName {
+			t.Errorf("Should report %s as container running it, but is %s", inv.imageName, inv.image.ContainerKey())
+		}
+	}
+	if len(result.ImagesNewerVersions) != 2 {
+		t.Errorf("Should check both images, but is %v", result.ImagesNewerVersions)
+	}
+}
+
+func TestContainerFiltersMatchWorkloadNames(t *testing.T) {
+	containerFilters, _ := ParseContainerFilters([]string{"name=api*"}, nil)
+
+	var names []string
+	for _, workload := range []KubernetesWorkload{
+		{Name: "shop/deployment/api/c0", Workload: "api", Image: "author/api:1.0.0"},
+		{Name: "shop/deployment/api-gateway/c0", Workload: "api-gateway", Image: "author/gateway:1.0.0"},
+		{Name: "api/deployment/web/c0", Workload: "web", Image: "nginx:1.25.3"},
+	} {
+		if containerFilters.Matches(workloadAsContainer(workload)) {
+			names = append(names, workload.Name)
+		}
+	}
+
+	if expected := []string{"shop/deployment/api/c0", "shop/deployment/api-gateway/c0"}; !reflect.DeepEqual(expected, names) {
+		t.Errorf("Should be %v, but is %v", expected, names)
+	}
+}
+
+func TestKubernetesConfigReadsKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvchk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "ca.crt"), []byte("-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kubeconfigPath := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(kubeconfigPath, []byte(`apiVersion: v1
+kind: Config
+current-context: eks
+contexts:
+- name: staging
+  context:
+    cluster: staging
+    user: staging
+- name: eks
+  context:
+    cluster: eks
+    user: eks
+    namespace: shop
+clusters:
+- name: staging
+  cluster:
+    server: https://staging.example.com:6443
+- name: eks
+  cluster:
+    server: https://eks.example.com
+    certificate-authority: ca.crt
+    proxy-url: http://proxy.example.com:3128
+users:
+- name: staging
+  user:
+    token: staging-token
+- name: eks
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+      args: ["eks", "get-token", "--cluster-name", "shop"]
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restConfig, namespace, err := kubernetesConfig(kubeconfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restConfig.Host != "https://eks.example.com" || namespace != "shop" {
+		t.Errorf("Should be eks cluster in shop namespace, but is %s in %s", restConfig.Host, namespace)
+	}
+	if expected := filepath.Join(dir, "ca.crt"); restConfig.CAFile != expected {
+		t.Errorf("Should be %s, but is %s", expected, restConfig.CAFile)
+	}
+	if restConfig.ExecProvider == nil || restConfig.ExecProvider.Command != "aws" {
+		t.Errorf("Should use exec credential plugin, but is %+v", restConfig.ExecProvider)
+	}
+	if restConfig.Proxy == nil {
+		t.Error("Should use proxy of the cluster")
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	mediaTypeOciIndex           = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOciManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+
+	unknownPlatformOS = "unknown"
+	defaultPlatformOS = "linux"
+)
+
+var manifestMediaTypes = []string{mediaTypeOciIndex, mediaTypeDockerManifestList, mediaTypeOciManifest, mediaTypeDockerManifest}
+
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	segments := []string{p.OS, p.Architecture}
+	if p.Variant != "" {
+		segments = append(segments, p.Variant)
+	}
+	return strings.Join(segments, "/")
+}
+
+// Matches tells whether the platform can run images built for the other one. Variant is compared
+// only when both platforms specify it.
+func (p Platform) Matches(other Platform) bool {
+	if p.OS != other.OS || p.Architecture != other.Architecture {
+		return false
+	}
+	return p.Variant == "" || other.Variant == "" || p.Variant == other.Variant
+}
+
+type Descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *Platform `json:"platform,omitempty"`
+}
+
+type Manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    Descriptor   `json:"config"`
+	Layers    []Descriptor `json:"layers"`
+	Manifests []Descriptor `json:"manifests"`
+}
+
+func (m Manifest) isIndex() bool {
+	return m.MediaType == mediaTypeOciIndex || m.MediaType == mediaTypeDockerManifestList || len(m.Manifests) > 0
+}
+
+type ImageConfig struct {
+	Created time.Time `json:"created"`
+	Platform
+}
+
+type ImageMetadata struct {
+	Created   time.Time
+	Platforms []Platform
+	// Size is the compressed size of the image for the selected platform.
+	Size int64
+}
+
+// Describe summarizes the metadata, comparing size to the other version if its metadata is known,
+// e.g. "released 2026-09-02, amd64/arm64, +12 MB".
+func (im ImageMetadata) Describe(other *ImageMetadata) string {
+	var details []string
+
+	if !im.Created.IsZero() {
+		details = append(details, fmt.Sprintf("released %s", im.Created.Format("2006-01-02")))
+	}
+
+	if len(im.Platforms) > 0 {
+		details = append(details, formatPlatforms(im.Platforms))
+	}
+
+	if other != nil && im.Size > 0 && other.Size > 0 {
+		details = append(details, formatSizeDifference(im.Size-other.Size))
+	}
+
+	return strings.Join(details, ", ")
+}
+
+// formatPlatforms lists only architectures when all platforms are Linux ones.
+func formatPlatforms(platforms []Platform) string {
+	onlyLinux := true
+	for _, platform := range platforms {
+		if platform.OS != defaultPlatformOS {
+			onlyLinux = false
+		}
+	}
+
+	var formatted []string
+	for _, platform := range platforms {
+		if onlyLinux {
+			formatted = append(formatted, strings.TrimPrefix(platform.String(), defaultPlatformOS+"/"))
+		} else {
+			formatted = append(formatted, platform.String())
+		}
+	}
+
+	return strings.Join(formatted, "/")
+}
+
+func formatSizeDifference(difference int64) string {
+	megabytes := float64(difference) / 1e6
+	if megabytes >= 0 {
+		return fmt.Sprintf("+%.0f MB", megabytes)
+	}
+	return fmt.Sprintf("%.0f MB", megabytes)
+}
+
+type MetadataDownloader struct {
+	apiClient *ApiClient
+	tokens    *TokenStore
+	platform  Platform
+}
+
+func NewMetadataDownloader(apiClient *ApiClient, tokens *TokenStore, platform Platform) MetadataDownloader {
+	return MetadataDownloader{apiClient: apiClient, tokens: tokens, platform: platform}
+}
+
+// LocalPlatform returns platform of the running program, used when platform is not known otherwise.
+func LocalPlatform() Platform {
+	return Platform{OS: defaultPlatformOS, Architecture: runtime.GOARCH}
+}
+
+// ParsePlatform parses platform in os/architecture/variant format, OS can be omitted for Linux.
+func ParsePlatform(value string) (Platform, error) {
+	segments := strings.Split(strings.ToLower(value), "/")
+
+	switch len(segments) {
+	case 1:
+		return NormalizePlatform(defaultPlatformOS, segments[0]), nil
+	case 2:
+		return NormalizePlatform(segments[0], segments[1]), nil
+	case 3:
+		platform := NormalizePlatform(segments[0], segments[1])
+		platform.Variant = segments[2]
+		return platform, nil
+	default:
+		return Platform{}, fmt.Errorf("%s is invalid platform format", value)
+	}
+}
+
+// NormalizePlatform converts architecture names reported by the kernel, e.g. by Docker daemon, to the ones
+// used in image manifests.
+func NormalizePlatform(os string, architecture string) Platform {
+	platform := Platform{OS: os, Architecture: architecture}
+
+	switch architecture {
+	case "x86_64", "x86-64":
+		platform.Architecture = "amd64"
+	case "aarch64":
+		platform.Architecture, platform.Variant = "arm64", "v8"
+	case "armhf", "armv7l":
+		platform.Architecture, platform.Variant = "arm", "v7"
+	case "armel", "armv6l":
+		platform.Architecture, platform.Variant = "arm", "v6"
+	case "i386", "i686":
+		platform.Architecture = "386"
+	}
+
+	return platform
+}
+
+const (
+	PlatformCheckFlag = "flag"
+	PlatformCheckHide = "hide"
+)
+
+// CheckImagePlatforms finds newer versions of the image that are not available for the platform, based on
+// downloaded metadata or on manifests otherwise. Depending on the mode, they are either flagged or removed from
+// newer versions.
+func (md MetadataDownloader) CheckImagePlatforms(inv *ImageNewerVersions, mode string) {
+	inv.platform = md.platform
+	inv.unavailable = make(map[string]bool)
+
+	var available []string
+	for _, newerVersion := range inv.newerVersions {
+		platforms, err := md.platforms(inv, newerVersion)
+		if err != nil {
+			log.Debugf("Failed to check platforms of %s:%s, %v\n", inv.image.Repository(), newerVersion, err)
+			available = append(available, newerVersion)
+			continue
+		}
+
+		if md.isAvailable(platforms) {
+			available = append(available, newerVersion)
+			continue
+		}
+
+		log.Debugf("%s:%s is not available for %s\n", inv.image.Repository(), newerVersion, md.platform)
+		if mode == PlatformCheckFlag {
+			inv.unavailable[newerVersion] = true
+			available = append(available, newerVersion)
+		}
+	}
+
+	inv.newerVersions = available
+}
+
+func (md MetadataDownloader) platforms(inv *ImageNewerVersions, tag string) ([]Platform, error) {
+	metadata, present := inv.metadata[tag]
+	if present {
+		return metadata.Platforms, nil
+	}
+
+	manifest, err := md.downloadManifest(inv.image, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.isIndex() {
+		_, platforms := md.selectPlatformManifest(manifest)
+		return platforms, nil
+	}
+
+	config, err := md.downloadConfig(inv.image, manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Platform{config.Platform}, nil
+}
+
+func (md MetadataDownloader) isAvailable(platforms []Platform) bool {
+	for _, platform := range platforms {
+		if md.platform.Matches(platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// DownloadImageMetadata fetches metadata of current and newer versions of the image. Failures are logged
+// and leave metadata of the affected version empty.
+func (md MetadataDownloader) DownloadImageMetadata(inv *ImageNewerVersions) {
+	inv.metadata = make(map[string]*ImageMetadata)
+
+	tags := append([]string{inv.image.Tag}, inv.newerVersions...)
+	for _, tag := range tags {
+		metadata, err := md.Download(inv.image, tag)
+		if err != nil {
+			log.Debugf("Failed to download metadata of %s:%s, %v\n", inv.image.Repository(), tag, err)
+			continue
+		}
+
+		inv.metadata[tag] = metadata
+	}
+}
+
+func (md MetadataDownloader) Download(image Image, tag string) (*ImageMetadata, error) {
+	manifest, err := md.downloadManifest(image, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &ImageMetadata{}
+
+	if manifest.isIndex() {
+		descriptor, platforms := md.selectPlatformManifest(manifest)
+		metadata.Platforms = platforms
+
+		if descriptor == nil {
+			return metadata, nil
+		}
+
+		manifest, err = md.downloadManifest(image, descriptor.Digest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	metadata.Size = manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		metadata.Size += layer.Size
+	}
+
+	config, err := md.downloadConfig(image, manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.Created = config.Created
+	if len(metadata.Platforms) == 0 {
+		metadata.Platforms = []Platform{config.Platform}
+	}
+
+	return metadata, nil
+}
+
+// selectPlatformManifest returns manifest matching the platform, falling back to the first one, and
+// all platforms available in the index. Entries with unknown platform, like attestations, are skipped.
+func (md MetadataDownloader) selectPlatformManifest(index *Manifest) (*Descriptor, []Platform) {
+	var selected *Descriptor
+	var platforms []Platform
+
+	for i, descriptor := range index.Manifests {
+		if descriptor.Platform == nil || descriptor.Platform.OS == unknownPlatformOS {
+			continue
+		}
+
+		platforms = append(platforms, *descriptor.Platform)
+
+		if selected == nil || (!md.platform.Matches(*selected.Platform) && md.platform.Matches(*descriptor.Platform)) {
+			selected = &index.Manifests[i]
+		}
+	}
+
+	return selected, platforms
+}
+
+func (md MetadataDownloader) downloadManifest(image Image, reference string) (*Manifest, error) {
+	response, err := md.authorizedRequest(image, func(token string) (*http.Response, error) {
+		return md.apiClient.GetManifest(image, reference, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var manifest *Manifest
+	err = json.NewDecoder(response.Body).Decode(&manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest, %v", err)
+	}
+
+	if manifest.MediaType == "" {
+		manifest.MediaType = response.Header.Get("Content-Type")
+	}
+
+	return manifest, nil
+}
+
+// Digest returns digest of the image index or manifest the tag currently points to, which pins the tag the way
+// Docker does for Swarm services.
+func (md MetadataDownloader) Digest(image Image, tag string) (string, error) {
+	response, err := md.authorizedRequest(image, func(token string) (*http.Response, error) {
+		return md.apiClient.GetManifest(image, tag, token)
+	})
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if digest := response.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifest, %v", err)
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content)), nil
+}
+
+func (md MetadataDownloader) downloadConfig(image Image, digest string) (*ImageConfig, error) {
+	response, err := md.authorizedRequest(image, func(token string) (*http.Response, error) {
+		return md.apiClient.GetBlob(image, digest, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var config *ImageConfig
+	err = json.NewDecoder(response.Body).Decode(&config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal image config, %v", err)
+	}
+
+	return config, nil
+}
+
+// authorizedRequest sends request with the stored token of the image. When registry rejects it,
+// e.g. because the token expired, anonymous token is requested and the request is repeated.
+func (md MetadataDownloader) authorizedRequest(image Image, request func(token string) (*http.Response, error)) (*http.Response, error) {
+	response, err := request(md.tokens.Get(image))
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusUnauthorized {
+		discardBody(response)
+
+		token, err := md.requestAnonymousToken(response.Header.Get("Www-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+
+		response, err = request(token)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode == http.StatusOK {
+			md.tokens.Set(image, token)
+		}
+	}
+
+	if response.StatusCode != http.StatusOK {
+		discardBody(response)
+		return nil, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return response, nil
+}
+
+func (md MetadataDownloader) requestAnonymousToken(wwwAuthenticate string) (string, error) {
+	authUrl, err := createAuthUrl(wwwAuthenticate)
+	if err != nil {
+		return "", err
+	}
+
+	tokenResponse, err := md.apiClient.GetToken(authUrl)
+	if err != nil {
+		return "", err
+	}
+	defer tokenResponse.Body.Close()
+
+	token, err := unmarshalToken(tokenResponse)
+	if err != nil {
+		return "", err
+	}
+
+	return prepareAuthHeader(token.Token), nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRegistryContent = map[string]string{
+	"/v2/author/image/manifests/1.0.0": `{
+		"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+		"config": {"digest": "sha256:config-1.0.0", "size": 1000000},
+		"layers": [{"digest": "sha256:layer", "size": 20000000}]
+	}`,
+	"/v2/author/image/manifests/1.1.0": `{
+		"mediaType": "application/vnd.oci.image.index.v1+json",
+		"manifests": [
+			{"digest": "sha256:amd64", "platform": {"os": "linux", "architecture": "amd64"}},
+			{"digest": "sha256:arm64", "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}},
+			{"digest": "sha256:attestation", "platform": {"os": "unknown", "architecture": "unknown"}}
+		]
+	}`,
+	"/v2/author/image/manifests/sha256:arm64": `{
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"config": {"digest": "sha256:config-1.1.0", "size": 1000000},
+		"layers": [{"digest": "sha256:layer", "size": 20000000}, {"digest": "sha256:layer2", "size": 12000000}]
+	}`,
+	"/v2/author/image/blobs/sha256:config-1.0.0": `{"created": "2026-01-10T10:00:00Z", "os": "linux", "architecture": "amd64"}`,
+	"/v2/author/image/blobs/sha256:config-1.1.0": `{"created": "2026-09-02T10:00:00Z", "os": "linux", "architecture": "arm64"}`,
+}
+
+func newTestRegistry(t *testing.T) (*httptest.Server, *ApiClient, Image) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, present := testRegistryContent[r.URL.Path]
+		if !present {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+
+	registry := strings.TrimPrefix(server.URL, "http://")
+
+	apiClient, err := NewApiClient(Config{Timeout: 5}, NewRateLimits(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiClient.UsePlainHttp(registry)
+
+	image, _ := getImageDetails(registry + "/author/image:1.0.0")
+
+	return server, apiClient, image
+}
+
+func TestMetadataDownloaderDownloadsMetadata(t *testing.T) {
+	server, apiClient, image := newTestRegistry(t)
+	defer server.Close()
+
+	inv := &ImageNewerVersions{imageName: image.LocalFullName, image: image, newerVersions: []string{"1.1.0"}}
+
+	platform := Platform{OS: "linux", Architecture: "arm64"}
+	NewMetadataDownloader(apiClient, NewTokenStore(), platform).DownloadImageMetadata(inv)
+
+	expected := &ImageMetadata{
+		Created:   time.Date(2026, 9, 2, 10, 0, 0, 0, time.UTC),
+		Platforms: []Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		Size:      33000000,
+	}
+	metadata := inv.metadata["1.1.0"]
+	if !reflect.DeepEqual(expected, metadata) {
+		t.Errorf("Should be %v, but is %v", expected, metadata)
+	}
+
+	expectedDescription := "1.1.0 (released 2026-09-02, amd64/arm64/v8, +12 MB)"
+	if description := inv.formatNewerVersions(); description != expectedDescription {
+		t.Errorf("Should be %s, but is %s", expectedDescription, description)
+	}
+}
+
+func TestMetadataDownloaderChecksPlatforms(t *testing.T) {
+	server, apiClient, image := newTestRegistry(t)
+	defer server.Close()
+
+	cases := []struct {
+		platform      string
+		mode          string
+		newerVersions []string
+		unavailable   map[string]bool
+	}{
+		{"linux/arm64", PlatformCheckHide, []string{"1.1.0"}, map[string]bool{}},
+		{"linux/ppc64le", PlatformCheckFlag, []string{"1.1.0"}, map[string]bool{"1.1.0": true}},
+		{"linux/ppc64le", PlatformCheckHide, nil, map[string]bool{}},
+	}
+
+	for _, c := range cases {
+		platform, _ := ParsePlatform(c.platform)
+		inv := &ImageNewerVersions{imageName: image.LocalFullName, image: image, newerVersions: []string{"1.1.0"}}
+
+		NewMetadataDownloader(apiClient, NewTokenStore(), platform).CheckImagePlatforms(inv, c.mode)
+
+		if !reflect.DeepEqual(c.newerVersions, inv.newerVersions) || !reflect.DeepEqual(c.unavailable, inv.unavailable) {
+			t.Errorf("%s/%s should be %v %v, but is %v %v", c.platform, c.mode, c.newerVersions, c.unavailable, inv.newerVersions, inv.unavailable)
+		}
+	}
+}
+
+func TestMetadataDownloaderResolvesDigest(t *testing.T) {
+	server, apiClient, image := newTestRegistry(t)
+	defer server.Close()
+
+	digest, err := NewMetadataDownloader(apiClient, NewTokenStore(), Platform{}).Digest(image, "1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(testRegistryContent["/v2/author/image/manifests/1.1.0"])))
+	if digest != expected {
+		t.Errorf("Should be %s, but is %s", expected, digest)
+	}
+
+	_, err = NewMetadataDownloader(apiClient, NewTokenStore(), Platform{}).Digest(image, "2.0.0")
+	if err == nil {
+		t.Error("Should fail when tag does not exist")
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	cases := map[string]Platform{
+		"arm64":         {OS: "linux", Architecture: "arm64"},
+		"linux/aarch64": {OS: "linux", Architecture: "arm64", Variant: "v8"},
+		"linux/arm/v7":  {OS: "linux", Architecture: "arm", Variant: "v7"},
+		"windows/amd64": {OS: "windows", Architecture: "amd64"},
+	}
+
+	for value, expected := range cases {
+		platform, err := ParsePlatform(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if platform != expected {
+			t.Errorf("Should be %v, but is %v", expected, platform)
+		}
+	}
+}
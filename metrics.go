@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestDurationBuckets are upper bounds of registry request duration histogram in seconds.
+var requestDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	registry string
+	code     string
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range requestDurationBuckets {
+		if value <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// Metrics collects data of checks and registry requests and exposes it in Prometheus text format, either over
+// HTTP or in a file for node_exporter textfile collector. Methods can be called on nil Metrics.
+type Metrics struct {
+	file string
+
+	mutex               sync.Mutex
+	requestDurations    map[requestKey]*histogram
+	checkErrors         map[string]uint64
+	checkSkips          map[string]uint64
+	imagesNewerVersions ImagesNewerVersions
+	lastRun             time.Time
+}
+
+func NewMetrics(config Config) *Metrics {
+	return &Metrics{
+		file:             config.MetricsFile,
+		requestDurations: make(map[requestKey]*histogram),
+		checkErrors:      make(map[string]uint64),
+		checkSkips:       make(map[string]uint64),
+	}
+}
+
+// ObserveRequest records duration of a registry request, code is the status code or error when the request failed.
+func (m *Metrics) ObserveRequest(registry string, response *http.Response, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	key := requestKey{registry: registry, code: "error"}
+	if response != nil {
+		key.code = strconv.Itoa(response.StatusCode)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.requestDurations[key] == nil {
+		m.requestDurations[key] = &histogram{buckets: make([]uint64, len(requestDurationBuckets))}
+	}
+	m.requestDurations[key].observe(duration.Seconds())
+}
+
+// ObserveFailures counts images that could not be checked. Skipped images, e.g. with tags that are not versions,
+// are counted apart from errors, so that they do not trigger alerts.
+func (m *Metrics) ObserveFailures(failures []*ImageFailure) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, failure := range failures {
+		if failure.State() == StateSkipped {
+			m.checkSkips[failure.Reason]++
+		} else {
+			m.checkErrors[failure.Reason]++
+		}
+	}
+}
+
+// ObserveResult records newer versions of all containers and writes the metrics file, if configured.
+func (m *Metrics) ObserveResult(result CheckResult, checkedAt time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	m.imagesNewerVersions = result.ImagesNewerVersions
+	m.lastRun = checkedAt
+	m.mutex.Unlock()
+
+	if m.file == "" {
+		return
+	}
+
+	err := m.writeFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write metrics to %s, %v\n", m.file, err)
+	}
+}
+
+// writeFile writes metrics to a temporary file first, so that the collector never reads partial metrics.
+func (m *Metrics) writeFile() error {
+	var content bytes.Buffer
+	m.Write(&content)
+
+	temporary, err := ioutil.TempFile(filepath.Dir(m.file), filepath.Base(m.file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	_, err = temporary.Write(content.Bytes())
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// Temporary files are created with 0600, the collector may run as another user.
+	err = os.Chmod(temporary.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), m.file)
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.Write(w)
+}
+
+// Write writes metrics in Prometheus text exposition format.
+func (m *Metrics) Write(w io.Writer) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	fmt.Fprintln(w, "# HELP dvchk_image_updates_available Number of newer versions available for image of container.")
+	fmt.Fprintln(w, "# TYPE dvchk_image_updates_available gauge")
+	for _, inv := range m.imagesNewerVersions {
+		fmt.Fprintf(w, "dvchk_image_updates_available{image=%s,container=%s,host=%s,level=%s} %d\n",
+			quoteLabel(inv.imageName), quoteLabel(inv.image.Container), quoteLabel(inv.image.Host), quoteLabel(inv.Level().String()), len(inv.newerVersions))
+	}
+
+	fmt.Fprintln(w, "# HELP dvchk_registry_request_duration_seconds Duration of registry requests.")
+	fmt.Fprintln(w, "# TYPE dvchk_registry_request_duration_seconds histogram")
+	for _, key := range m.sortedRequestKeys() {
+		h := m.requestDurations[key]
+		labels := fmt.Sprintf("registry=%s,code=%s", quoteLabel(key.registry), quoteLabel(key.code))
+
+		for i, bound := range requestDurationBuckets {
+			fmt.Fprintf(w, "dvchk_registry_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(bound), h.buckets[i])
+		}
+		fmt.Fprintf(w, "dvchk_registry_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "dvchk_registry_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "dvchk_registry_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP dvchk_check_errors_total Number of images that could not be checked by reason.")
+	fmt.Fprintln(w, "# TYPE dvchk_check_errors_total counter")
+	for _, reason := range sortedReasons(m.checkErrors) {
+		fmt.Fprintf(w, "dvchk_check_errors_total{reason=%s} %d\n", quoteLabel(reason), m.checkErrors[reason])
+	}
+
+	fmt.Fprintln(w, "# HELP dvchk_check_skipped_total Number of images that were skipped by reason.")
+	fmt.Fprintln(w, "# TYPE dvchk_check_skipped_total counter")
+	for _, reason := range sortedReasons(m.checkSkips) {
+		fmt.Fprintf(w, "dvchk_check_skipped_total{reason=%s} %d\n", quoteLabel(reason), m.checkSkips[reason])
+	}
+
+	if !m.lastRun.IsZero() {
+		fmt.Fprintln(w, "# HELP dvchk_last_run_timestamp_seconds Time of the last finished check.")
+		fmt.Fprintln(w, "# TYPE dvchk_last_run_timestamp_seconds gauge")
+		fmt.Fprintf(w, "dvchk_last_run_timestamp_seconds %d\n", m.lastRun.Unix())
+	}
+}
+
+func sortedReasons(counts map[string]uint64) []string {
+	var reasons []string
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+func (m *Metrics) sortedRequestKeys() []requestKey {
+	var keys []requestKey
+	for key := range m.requestDurations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].registry != keys[j].registry {
+			return keys[i].registry < keys[j].registry
+		}
+		return keys[i].code < keys[j].code
+	})
+	return keys
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
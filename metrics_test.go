@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	metrics := NewMetrics(Config{})
+
+	metrics.ObserveRequest("registry-1.docker.io", &http.Response{StatusCode: 200}, 80*time.Millisecond)
+	metrics.ObserveRequest("registry-1.docker.io", &http.Response{StatusCode: 200}, 2*time.Second)
+	metrics.ObserveRequest("ghcr.io", nil, 5*time.Second)
+	metrics.ObserveFailures([]*ImageFailure{{Reason: FailureUnsupportedTag}, {Reason: FailureUnsupportedTag}, {Reason: FailureDownload}})
+	metrics.ObserveResult(CheckResult{ImagesNewerVersions: ImagesNewerVersions{
+		newTestNewerVersions("author/api:1.0.0", "api", "1.0.1", "2.0.0"),
+		newTestNewerVersions("postgres:15.3", "db"),
+	}}, time.Unix(1792303200, 0))
+
+	var output bytes.Buffer
+	metrics.Write(&output)
+
+	expected := `# HELP dvchk_image_updates_available Number of newer versions available for image of container.
+# TYPE dvchk_image_updates_available gauge
+dvchk_image_updates_available{image="author/api:1.0.0",container="api",host="",level="major"} 2
+dvchk_image_updates_available{image="postgres:15.3",container="db",host="",level="none"} 0
+# HELP dvchk_registry_request_duration_seconds Duration of registry requests.
+# TYPE dvchk_registry_request_duration_seconds histogram
+dvchk_registry_request_duration_seconds_bucket{registry="ghcr.io",code="error",le="0.05"} 0
+dvchk_registry_request_duration_seconds_bucket{registry="ghcr.io",code="error",le="0.1"} 0
+dvchk_registry_request_duration_seconds_bucket{registry="ghcr.io",code="error",le="0.25"} 0
+dvchk_registry_request_duration_seconds_bucket{registry="ghcr.io",code="error",le="0.5"} 0
+dvchk_registry_request_duration_seconds_bucket{registry="ghcr.io",code="error",le="1"} 0
+dvchk_registry_request_duration_seconds_bucket{registry="ghcr.io",code="error",le="2.5"} 0
+dvchk_registry_request_duration_seconds_bucket{registry="ghcr.io",code="error",le="5"} 1
+dvchk_registry_request_duration_seconds_bucket{registry="ghcr.io",code="error",le="10"} 1
+dvchk_registry_request_duration_seconds_bucket{registry="ghcr.io",code="error",le="+Inf"} 1
+dvchk_registry_request_duration_seconds_sum{registry="ghcr.io",code="error"} 5
+dvchk_registry_request_duration_seconds_count{registry="ghcr.io",code="error"} 1
+dvchk_registry_request_duration_seconds_bucket{registry="registry-1.docker.io",code="200",le="0.05"} 0
+dvchk_registry_request_duration_seconds_bucket{registry="registry-1.docker.io",code="200",le="0.1"} 1
+dvchk_registry_request_duration_seconds_bucket{registry="registry-1.docker.io",code="200",le="0.25"} 1
+dvchk_registry_request_duration_seconds_bucket{registry="registry-1.docker.io",code="200",le="0.5"} 1
+dvchk_registry_request_duration_seconds_bucket{registry="registry-1.docker.io",code="200",le="1"} 1
+dvchk_registry_request_duration_seconds_bucket{registry="registry-1.docker.io",code="200",le="2.5"} 2
+dvchk_registry_request_duration_seconds_bucket{registry="registry-1.docker.io",code="200",le="5"} 2
+dvchk_registry_request_duration_seconds_bucket{registry="registry-1.docker.io",code="200",le="10"} 2
+dvchk_registry_request_duration_seconds_bucket{registry="registry-1.docker.io",code="200",le="+Inf"} 2
+dvchk_registry_request_duration_seconds_sum{registry="registry-1.docker.io",code="200"} 2.08
+dvchk_registry_request_duration_seconds_count{registry="registry-1.docker.io",code="200"} 2
+# HELP dvchk_check_errors_total Number of images that could not be checked by reason.
+# TYPE dvchk_check_errors_total counter
+dvchk_check_errors_total{reason="download"} 1
+# HELP dvchk_check_skipped_total Number of images that were skipped by reason.
+# TYPE dvchk_check_skipped_total counter
+dvchk_check_skipped_total{reason="unsupported_tag"} 2
+# HELP dvchk_last_run_timestamp_seconds Time of the last finished check.
+# TYPE dvchk_last_run_timestamp_seconds gauge
+dvchk_last_run_timestamp_seconds 1792303200
+`
+	if output.String() != expected {
+		t.Errorf("Should be %s, but is %s", expected, output.String())
+	}
+}
+
+func TestApiClientObservesRequests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics(Config{})
+	apiClient, _ := NewApiClient(Config{Retries: 3, Timeout: 5}, NewRateLimits(), metrics)
+	apiClient.baseDelay = time.Millisecond
+
+	response, err := apiClient.get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discardBody(response)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	for _, code := range []string{"200", "503"} {
+		if h := metrics.requestDurations[requestKey{registry: host, code: code}]; h == nil || h.count != 1 {
+			t.Errorf("Should observe 1 request with code %s, but is %v", code, h)
+		}
+	}
+}
+
+func TestMetricsWritesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvchk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "dvchk.prom")
+	metrics := NewMetrics(Config{MetricsFile: file})
+	metrics.ObserveResult(CheckResult{}, time.Unix(1792303200, 0))
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("dvchk_last_run_timestamp_seconds %d\n", 1792303200)
+	if !strings.HasSuffix(string(content), expected) {
+		t.Errorf("Should end with %s, but is %s", expected, content)
+	}
+}
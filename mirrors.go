@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type RegistryMirror struct {
+	Host      string
+	PlainHttp bool
+}
+
+// ParseRegistryMirrors accepts mirrors in the format of Docker daemon registry-mirrors setting,
+// e.g. https://mirror.gcr.io. Scheme defaults to HTTPS when not specified.
+func ParseRegistryMirrors(mirrors []string) ([]RegistryMirror, error) {
+	var registryMirrors []RegistryMirror
+
+	for _, mirror := range mirrors {
+		mirror = strings.TrimSpace(mirror)
+		if mirror == "" {
+			continue
+		}
+
+		if !strings.Contains(mirror, "://") {
+			mirror = schemeHttps + "://" + mirror
+		}
+
+		mirrorUrl, err := url.Parse(mirror)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry mirror %s, %v", mirror, err)
+		}
+
+		if mirrorUrl.Scheme != schemeHttp && mirrorUrl.Scheme != schemeHttps {
+			return nil, fmt.Errorf("invalid registry mirror %s, unsupported scheme %s", mirror, mirrorUrl.Scheme)
+		}
+
+		if strings.Trim(mirrorUrl.Path, "/") != "" {
+			return nil, fmt.Errorf("invalid registry mirror %s, path is not supported", mirror)
+		}
+
+		registryMirrors = append(registryMirrors, RegistryMirror{Host: mirrorUrl.Host, PlainHttp: mirrorUrl.Scheme == schemeHttp})
+	}
+
+	return registryMirrors, nil
+}
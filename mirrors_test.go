@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTagDownloaderUsesRegistryMirror(t *testing.T) {
+	var paths []string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/v2/library/image/tags/list" {
+			_, _ = w.Write([]byte(`{"name": "library/image", "tags": ["1.0.0", "1.1.0"]}`))
+		}
+	}))
+	defer mirror.Close()
+
+	mirrors, err := ParseRegistryMirrors([]string{mirror.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiClient, err := NewApiClient(Config{Timeout: 5}, NewRateLimits(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagDownloader := NewTagDownloader(apiClient, mirrors, NewTokenStore())
+
+	image, _ := getImageDetails("image:1.0.0")
+	status, tags, _, err := tagDownloader.DownloadWithoutAuth(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"1.0.0", "1.1.0"}
+	if status != StatusImgSuccessful || !reflect.DeepEqual(expected, tags) {
+		t.Errorf("Should be %v, but is %v", expected, tags)
+	}
+
+	expectedPaths := []string{"/v2/", "/v2/library/image/tags/list"}
+	if !reflect.DeepEqual(expectedPaths, paths) {
+		t.Errorf("Should be %v, but is %v", expectedPaths, paths)
+	}
+}
+
+func TestParseRegistryMirrors(t *testing.T) {
+	mirrors, err := ParseRegistryMirrors([]string{"https://mirror.gcr.io", "http://cache.local:5000/", "mirror.local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []RegistryMirror{
+		{Host: "mirror.gcr.io"},
+		{Host: "cache.local:5000", PlainHttp: true},
+		{Host: "mirror.local"},
+	}
+	if !reflect.DeepEqual(expected, mirrors) {
+		t.Errorf("Should be %v, but is %v", expected, mirrors)
+	}
+
+	_, err = ParseRegistryMirrors([]string{"https://mirror.local/path"})
+	if err == nil || !strings.Contains(err.Error(), "path is not supported") {
+		t.Errorf("Should fail on mirror with path, but error is %v", err)
+	}
+}
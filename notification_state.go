@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// NotificationState remembers which newer versions of images were already notified by each notifier, so that
+// the same version is notified only once. It is persisted in a JSON file when the file is given.
+type NotificationState struct {
+	file string
+
+	// Notifiers maps hashes of notifier identifiers, which may contain secrets like webhook tokens, to their state.
+	Notifiers map[string]*NotifierState `json:"notifiers"`
+}
+
+// NotifierState remembers newer versions a single notifier sent.
+type NotifierState struct {
+	// Notified maps image names to their notified newer versions and the time they were notified first.
+	Notified     map[string]map[string]time.Time `json:"notified"`
+	LastReminder time.Time                       `json:"lastReminder"`
+}
+
+func LoadNotificationState(file string) (*NotificationState, error) {
+	state := &NotificationState{file: file, Notifiers: make(map[string]*NotifierState)}
+	if file == "" {
+		return state, nil
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, state)
+	if err != nil {
+		return nil, err
+	}
+	if state.Notifiers == nil {
+		state.Notifiers = make(map[string]*NotifierState)
+	}
+
+	return state, nil
+}
+
+// Save writes the state to a temporary file first, so that the state is not corrupted when writing fails.
+func (ns *NotificationState) Save() error {
+	if ns.file == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(ns, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temporary, err := ioutil.TempFile(filepath.Dir(ns.file), filepath.Base(ns.file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	_, err = temporary.Write(content)
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), ns.file)
+}
+
+// Prune forgets images that are neither checked nor failed in the result, e.g. because their containers were
+// removed, so that the state does not grow with every image ever checked.
+func (ns *NotificationState) Prune(result CheckResult) {
+	present := make(map[string]bool)
+	for _, inv := range result.ImagesNewerVersions {
+		present[inv.imageName] = true
+	}
+	for _, failure := range result.Failures {
+		present[failure.Image.LocalFullName] = true
+	}
+
+	for _, state := range ns.Notifiers {
+		for imageName := range state.Notified {
+			if !present[imageName] {
+				delete(state.Notified, imageName)
+			}
+		}
+	}
+}
+
+// Notifier returns state of the notifier with given identifier, which is empty when it did not notify yet.
+func (ns *NotificationState) Notifier(id string) *NotifierState {
+	hash := sha256.Sum256([]byte(id))
+	key := hex.EncodeToString(hash[:])
+
+	state, present := ns.Notifiers[key]
+	if !present {
+		state = &NotifierState{}
+		ns.Notifiers[key] = state
+	}
+	if state.Notified == nil {
+		state.Notified = make(map[string]map[string]time.Time)
+	}
+	return state
+}
+
+// NotNotified returns images with only those newer versions that were not notified yet.
+func (ns *NotifierState) NotNotified(imagesNewerVersions ImagesNewerVersions) ImagesNewerVersions {
+	var notNotified ImagesNewerVersions
+
+	for _, inv := range imagesNewerVersions {
+		var newerVersions []string
+		for _, newerVersion := range inv.newerVersions {
+			if _, notified := ns.Notified[inv.imageName][newerVersion]; !notified {
+				newerVersions = append(newerVersions, newerVersion)
+			}
+		}
+
+		if len(newerVersions) > 0 {
+			inv.newerVersions = newerVersions
+			notNotified = append(notNotified, inv)
+		}
+	}
+
+	return notNotified
+}
+
+// Update marks all newer versions as notified and forgets versions of checked images that are no longer pending,
+// e.g. because the container was updated. Versions of images which were not checked, e.g. because the check failed,
+// are kept.
+func (ns *NotifierState) Update(imagesNewerVersions ImagesNewerVersions, now time.Time) {
+	pending := make(map[string]map[string]time.Time)
+
+	for _, inv := range imagesNewerVersions {
+		if pending[inv.imageName] == nil {
+			pending[inv.imageName] = make(map[string]time.Time)
+		}
+
+		for _, newerVersion := range inv.newerVersions {
+			notifiedAt, present := ns.Notified[inv.imageName][newerVersion]
+			if !present {
+				notifiedAt = now
+			}
+			pending[inv.imageName][newerVersion] = notifiedAt
+		}
+	}
+
+	for imageName, versions := range pending {
+		if len(versions) == 0 {
+			delete(ns.Notified, imageName)
+		} else {
+			ns.Notified[imageName] = versions
+		}
+	}
+}
+
+// ReminderDue tells whether all pending versions should be notified again.
+func (ns *NotifierState) ReminderDue(interval time.Duration, now time.Time) bool {
+	return interval > 0 && !ns.LastReminder.IsZero() && now.Sub(ns.LastReminder) >= interval
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNotifiersNotifyOnlyNewVersions(t *testing.T) {
+	var received []receivedWebhook
+	server := newTestWebhookServer(&received)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "dvchk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := Config{Timeout: 5, NoProxy: "*", NotificationState: filepath.Join(dir, "state.json"), Webhooks: []WebhookConfig{{Url: server.URL}}}
+
+	checks := []ImagesNewerVersions{
+		{newTestNewerVersions("postgres:15.3", "db", "15.4")},
+		{newTestNewerVersions("postgres:15.3", "db", "15.4")},
+		{newTestNewerVersions("postgres:15.3", "db", "15.4", "15.5")},
+	}
+	for _, check := range checks {
+		// Notifiers are created for each check to read the state from the file like separate runs do.
+		notifiers, err := CreateNotifiers(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		notifiers.Notify(CheckResult{ImagesNewerVersions: check})
+	}
+
+	var notifiedVersions [][]string
+	for _, webhook := range received {
+		var notification Notification
+		_ = json.Unmarshal(webhook.body, &notification)
+		notifiedVersions = append(notifiedVersions, notification.Images[0].NewerVersions)
+	}
+
+	expected := [][]string{{"15.4"}, {"15.5"}}
+	if !reflect.DeepEqual(expected, notifiedVersions) {
+		t.Errorf("Should be %v, but is %v", expected, notifiedVersions)
+	}
+}
+
+func TestNotifiersRemindPendingVersions(t *testing.T) {
+	var received []receivedWebhook
+	server := newTestWebhookServer(&received)
+	defer server.Close()
+
+	notifiers, err := CreateNotifiers(Config{Timeout: 5, NoProxy: "*", NotificationReminder: time.Hour, Webhooks: []WebhookConfig{{Url: server.URL}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := ImagesNewerVersions{newTestNewerVersions("postgres:15.3", "db", "15.4")}
+	notifiers.Notify(CheckResult{ImagesNewerVersions: results})
+	notifiers.Notify(CheckResult{ImagesNewerVersions: results})
+
+	state := notifiers.notifierState(0)
+	state.LastReminder = state.LastReminder.Add(-time.Hour)
+	notifiers.Notify(CheckResult{ImagesNewerVersions: results})
+
+	if len(received) != 2 {
+		t.Fatalf("Should receive 2 webhooks, but received %d", len(received))
+	}
+
+	var reminder Notification
+	_ = json.Unmarshal(received[1].body, &reminder)
+	if !reminder.Reminder || !reflect.DeepEqual([]string{"15.4"}, reminder.Images[0].NewerVersions) {
+		t.Errorf("Should be reminder of 15.4, but is %v", reminder)
+	}
+}
+
+func TestNotifiersNotifyAgainOnlyThroughFailedNotifiers(t *testing.T) {
+	var received []receivedWebhook
+	server := newTestWebhookServer(&received)
+	defer server.Close()
+
+	failing := true
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer failingServer.Close()
+
+	notifiers, err := CreateNotifiers(Config{Timeout: 5, NoProxy: "*", Webhooks: []WebhookConfig{{Url: server.URL}, {Url: failingServer.URL}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := ImagesNewerVersions{newTestNewerVersions("postgres:15.3", "db", "15.4")}
+	notifiers.Notify(CheckResult{ImagesNewerVersions: results})
+	failing = false
+	notifiers.Notify(CheckResult{ImagesNewerVersions: results})
+
+	if len(received) != 1 {
+		t.Errorf("Should notify once through working notifier, but notified %d times", len(received))
+	}
+	if notified := notifiers.notifierState(1).Notified; len(notified["postgres:15.3"]) != 1 {
+		t.Errorf("Should notify 15.4 through failed notifier on the next check, but notified %v", notified)
+	}
+}
+
+func TestNotifierStateForgetsVersionsNoLongerPending(t *testing.T) {
+	state, _ := LoadNotificationState("")
+	notifierState := state.Notifier("https://example.com/hooks/dvchk")
+
+	first := time.Date(2026, 10, 1, 6, 0, 0, 0, time.UTC)
+	notifierState.Update(ImagesNewerVersions{
+		newTestNewerVersions("postgres:15.3", "db", "15.4"),
+		newTestNewerVersions("redis:7.0.0", "cache", "7.0.1"),
+		newTestNewerVersions("author/api:1.0.0", "api", "1.0.1"),
+	}, first)
+
+	// Redis was updated and api failed to be checked.
+	second := first.Add(24 * time.Hour)
+	notifierState.Update(ImagesNewerVersions{
+		newTestNewerVersions("postgres:15.3", "db", "15.4", "15.5"),
+		newTestNewerVersions("redis:7.0.0", "cache"),
+	}, second)
+
+	expected := map[string]map[string]time.Time{
+		"postgres:15.3":    {"15.4": first, "15.5": second},
+		"author/api:1.0.0": {"1.0.1": first},
+	}
+	if !reflect.DeepEqual(expected, notifierState.Notified) {
+		t.Errorf("Should be %v, but is %v", expected, notifierState.Notified)
+	}
+}
+
+func TestNotificationStatePrunesImagesNotInResult(t *testing.T) {
+	state, _ := LoadNotificationState("")
+	notifierState := state.Notifier("https://example.com/hooks/dvchk")
+
+	now := time.Date(2026, 10, 1, 6, 0, 0, 0, time.UTC)
+	notifierState.Update(ImagesNewerVersions{
+		newTestNewerVersions("postgres:15.3", "db", "15.4"),
+		newTestNewerVersions("redis:7.0.0", "cache", "7.0.1"),
+		newTestNewerVersions("author/api:1.0.0", "api", "1.0.1"),
+	}, now)
+
+	// Redis container was removed and api failed to be checked.
+	state.Prune(CheckResult{
+		ImagesNewerVersions: ImagesNewerVersions{newTestNewerVersions("postgres:15.3", "db", "15.4")},
+		Failures:            []*ImageFailure{{Image: Image{LocalFullName: "author/api:1.0.0"}, Reason: FailureDownload}},
+	})
+
+	expected := map[string]map[string]time.Time{
+		"postgres:15.3":    {"15.4": now},
+		"author/api:1.0.0": {"1.0.1": now},
+	}
+	if !reflect.DeepEqual(expected, notifierState.Notified) {
+		t.Errorf("Should be %v, but is %v", expected, notifierState.Notified)
+	}
+}
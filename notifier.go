@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Notification describes images with newer versions, it is the model notifier templates are rendered with.
+type Notification struct {
+	Images []NotificationImage `json:"images"`
+	// Reminder is set when the notification repeats versions that were already notified.
+	Reminder bool `json:"reminder"`
+}
+
+type NotificationImage struct {
+	Image         string   `json:"image"`
+	Container     string   `json:"container"`
+	Host          string   `json:"host,omitempty"`
+	CurrentTag    string   `json:"current"`
+	NewerVersions []string `json:"newer"`
+	Level         string   `json:"level"`
+}
+
+// Summary renders the notification as plain text, one line per image.
+func (n Notification) Summary() string {
+	var lines []string
+	for _, image := range n.Images {
+		name := image.Image
+		if image.Container != "" {
+			name = fmt.Sprintf("%s [%s]", image.Image, containerKey(image.Host, image.Container))
+		} else if image.Host != "" {
+			name = fmt.Sprintf("%s on %s", image.Image, image.Host)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s (%s)", name, strings.Join(image.NewerVersions, ", "), image.Level))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ContainerKey returns name of the container prefixed by its host, when multiple hosts are checked.
+func (ni NotificationImage) ContainerKey() string {
+	return containerKey(ni.Host, ni.Container)
+}
+
+func (n Notification) Title() string {
+	if n.Reminder {
+		return fmt.Sprintf("Updates of %d images are still pending", len(n.Images))
+	}
+	if len(n.Images) == 1 {
+		return "There is a new version of 1 image"
+	}
+	return fmt.Sprintf("There are new versions of %d images", len(n.Images))
+}
+
+type Notifier interface {
+	Name() string
+	Notify(notification Notification) error
+}
+
+type NotificationFilterConfig struct {
+	Level  string
+	Images []string
+}
+
+// NotificationFilter selects images a notifier is interested in, by minimal update level
+// and by image name patterns.
+type NotificationFilter struct {
+	level  UpdateLevel
+	images []string
+}
+
+func NewNotificationFilter(config NotificationFilterConfig) (NotificationFilter, error) {
+	filter := NotificationFilter{level: UpdatePatch, images: config.Images}
+
+	if config.Level != "" {
+		level, err := ParseUpdateLevel(config.Level)
+		if err != nil {
+			return filter, err
+		}
+		filter.level = level
+	}
+
+	for _, pattern := range config.Images {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return filter, fmt.Errorf("invalid image pattern %s, %v", pattern, err)
+		}
+	}
+
+	return filter, nil
+}
+
+func (nf NotificationFilter) Matches(inv ImageNewerVersions) bool {
+	if inv.Level() < nf.level {
+		return false
+	}
+
+	if len(nf.images) == 0 {
+		return true
+	}
+
+	for _, pattern := range nf.images {
+		if matched, _ := path.Match(pattern, inv.imageName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+type filteredNotifier struct {
+	notifier Notifier
+	filter   NotificationFilter
+}
+
+// Notifiers sends notifications about images with newer versions to all configured notifiers. Each newer
+// version is notified once by each notifier, all pending versions are notified again when reminder is due.
+type Notifiers struct {
+	notifiers []filteredNotifier
+	state     *NotificationState
+	reminder  time.Duration
+}
+
+func (ns *Notifiers) Add(notifier Notifier, filter NotificationFilter) {
+	ns.notifiers = append(ns.notifiers, filteredNotifier{notifier: notifier, filter: filter})
+}
+
+func (ns *Notifiers) Empty() bool {
+	return len(ns.notifiers) == 0
+}
+
+// Notify notifies newer versions of the result. Notified versions of images that are not in the result anymore
+// are forgotten.
+func (ns *Notifiers) Notify(result CheckResult) {
+	if ns.Empty() {
+		return
+	}
+
+	now := time.Now()
+	imagesNewerVersions := result.ImagesNewerVersions
+
+	for i, fn := range ns.notifiers {
+		state := ns.notifierState(i)
+
+		reminder := state.ReminderDue(ns.reminder, now)
+		notified := imagesNewerVersions
+		if !reminder {
+			notified = state.NotNotified(imagesNewerVersions)
+		}
+
+		notification := createNotification(notified, fn.filter)
+		if len(notification.Images) > 0 {
+			notification.Reminder = reminder
+
+			err := fn.notifier.Notify(notification)
+			if err != nil {
+				// Versions are notified again by this notifier on the next check.
+				fmt.Fprintf(os.Stderr, "Failed to send notification to %s, %v\n", fn.notifier.Name(), err)
+				continue
+			}
+		}
+
+		state.Update(imagesNewerVersions, now)
+		if reminder || state.LastReminder.IsZero() {
+			state.LastReminder = now
+		}
+	}
+
+	ns.state.Prune(result)
+	err := ns.state.Save()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save notification state, %v\n", err)
+	}
+}
+
+// notifierState returns state of the notifier, which is identified by its position and name, as the same URL
+// may be notified with different presets or filters.
+func (ns *Notifiers) notifierState(i int) *NotifierState {
+	return ns.state.Notifier(fmt.Sprintf("%d %s", i, ns.notifiers[i].notifier.Name()))
+}
+
+func createNotification(imagesNewerVersions ImagesNewerVersions, filter NotificationFilter) Notification {
+	var notification Notification
+
+	for _, inv := range imagesNewerVersions {
+		if len(inv.newerVersions) == 0 || !filter.Matches(inv) {
+			continue
+		}
+
+		notification.Images = append(notification.Images, NotificationImage{
+			Image:         inv.imageName,
+			Container:     inv.image.Container,
+			Host:          inv.image.Host,
+			CurrentTag:    inv.image.Tag,
+			NewerVersions: inv.newerVersions,
+			Level:         inv.Level().String(),
+		})
+	}
+
+	return notification
+}
+
+// CreateNotifiers creates notifiers configured in the config.
+func CreateNotifiers(config Config) (*Notifiers, error) {
+	state, err := LoadNotificationState(config.NotificationState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification state from %s, %v", config.NotificationState, err)
+	}
+
+	notifiers := &Notifiers{state: state, reminder: config.NotificationReminder}
+
+	webhooks := config.Webhooks
+	for _, url := range config.WebhookUrls {
+		webhooks = append(webhooks, WebhookConfig{Url: url})
+	}
+
+	for _, webhookConfig := range webhooks {
+		filter, err := NewNotificationFilter(webhookConfig.NotificationFilterConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter of webhook %s, %v", webhookConfig.Url, err)
+		}
+
+		webhook, err := NewWebhookNotifier(webhookConfig, config)
+		if err != nil {
+			return nil, err
+		}
+
+		notifiers.Add(webhook, filter)
+	}
+
+	if config.Smtp.Host != "" {
+		filter, err := NewNotificationFilter(NotificationFilterConfig{Level: config.Smtp.Level})
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter of email notifications, %v", err)
+		}
+
+		email, err := NewEmailNotifier(config)
+		if err != nil {
+			return nil, err
+		}
+
+		notifiers.Add(email, filter)
+	}
+
+	return notifiers, nil
+}
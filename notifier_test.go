@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type receivedWebhook struct {
+	contentType string
+	headers     http.Header
+	body        []byte
+}
+
+func newTestWebhookServer(received *[]receivedWebhook) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*received = append(*received, receivedWebhook{contentType: r.Header.Get("Content-Type"), headers: r.Header, body: body})
+	}))
+}
+
+func newTestNotifiers(t *testing.T, webhooks ...WebhookConfig) *Notifiers {
+	notifiers, err := CreateNotifiers(Config{Timeout: 5, NoProxy: "*", Webhooks: webhooks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notifiers
+}
+
+var testNotificationResults = ImagesNewerVersions{
+	newTestNewerVersions("author/api:1.0.0", "api", "1.0.1", "2.0.0"),
+	newTestNewerVersions("postgres:15.3", "db", "15.4"),
+	newTestNewerVersions("redis:7.0.0", "cache"),
+}
+
+func TestWebhookNotifierSendsJson(t *testing.T) {
+	var received []receivedWebhook
+	server := newTestWebhookServer(&received)
+	defer server.Close()
+
+	newTestNotifiers(t, WebhookConfig{Url: server.URL}).Notify(CheckResult{ImagesNewerVersions: testNotificationResults})
+
+	if len(received) != 1 {
+		t.Fatalf("Should receive 1 webhook, but received %d", len(received))
+	}
+	if received[0].contentType != contentTypeJson {
+		t.Errorf("Should be %s, but is %s", contentTypeJson, received[0].contentType)
+	}
+
+	var notification Notification
+	err := json.Unmarshal(received[0].body, &notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Notification{Images: []NotificationImage{
+		{Image: "author/api:1.0.0", Container: "api", CurrentTag: "1.0.0", NewerVersions: []string{"1.0.1", "2.0.0"}, Level: "major"},
+		{Image: "postgres:15.3", Container: "db", CurrentTag: "15.3", NewerVersions: []string{"15.4"}, Level: "minor"},
+	}}
+	if !reflect.DeepEqual(expected, notification) {
+		t.Errorf("Should be %v, but is %v", expected, notification)
+	}
+}
+
+func TestWebhookNotifierUsesPresets(t *testing.T) {
+	var received []receivedWebhook
+	server := newTestWebhookServer(&received)
+	defer server.Close()
+
+	newTestNotifiers(t,
+		WebhookConfig{Url: server.URL, Preset: "slack"},
+		WebhookConfig{Url: server.URL, Preset: "ntfy"},
+	).Notify(CheckResult{ImagesNewerVersions: testNotificationResults})
+
+	if len(received) != 2 {
+		t.Fatalf("Should receive 2 webhooks, but received %d", len(received))
+	}
+
+	expectedSlack := `{"text": "There are new versions of 2 images\nauthor/api:1.0.0 [api]: 1.0.1, 2.0.0 (major)\npostgres:15.3 [db]: 15.4 (minor)"}`
+	if string(received[0].body) != expectedSlack {
+		t.Errorf("Should be %s, but is %s", expectedSlack, received[0].body)
+	}
+
+	expectedNtfy := "author/api:1.0.0 [api]: 1.0.1, 2.0.0 (major)\npostgres:15.3 [db]: 15.4 (minor)"
+	if string(received[1].body) != expectedNtfy || received[1].headers.Get("Title") == "" {
+		t.Errorf("Should be %s with title, but is %s with title %s", expectedNtfy, received[1].body, received[1].headers.Get("Title"))
+	}
+}
+
+func TestWebhookNotifierFilters(t *testing.T) {
+	var received []receivedWebhook
+	server := newTestWebhookServer(&received)
+	defer server.Close()
+
+	newTestNotifiers(t,
+		WebhookConfig{Url: server.URL, Template: "{{ range .Images }}{{ .Image }};{{ end }}", NotificationFilterConfig: NotificationFilterConfig{Level: "major"}},
+		WebhookConfig{Url: server.URL, Template: "{{ range .Images }}{{ .Image }};{{ end }}", NotificationFilterConfig: NotificationFilterConfig{Images: []string{"postgres:*"}}},
+		WebhookConfig{Url: server.URL, NotificationFilterConfig: NotificationFilterConfig{Images: []string{"mysql:*"}}},
+	).Notify(CheckResult{ImagesNewerVersions: testNotificationResults})
+
+	var bodies []string
+	for _, webhook := range received {
+		bodies = append(bodies, string(webhook.body))
+	}
+
+	expected := []string{"author/api:1.0.0;", "postgres:15.3;"}
+	if !reflect.DeepEqual(expected, bodies) {
+		t.Errorf("Should be %v, but is %v", expected, bodies)
+	}
+}
+
+func TestCreateNotifiersFailsOnInvalidConfig(t *testing.T) {
+	invalid := []WebhookConfig{
+		{Url: "http://localhost", Preset: "unknown"},
+		{Url: "http://localhost", Template: "{{ .Images"},
+		{Url: "http://localhost", NotificationFilterConfig: NotificationFilterConfig{Level: "huge"}},
+	}
+
+	for _, webhook := range invalid {
+		if _, err := CreateNotifiers(Config{Webhooks: []WebhookConfig{webhook}}); err == nil {
+			t.Errorf("Should fail for %v", webhook)
+		}
+	}
+}
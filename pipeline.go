@@ -0,0 +1,369 @@
+package main
+
+import (
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"sort"
+	"time"
+)
+
+// CheckResult is the outcome of a single check of all containers.
+type CheckResult struct {
+	// Containers maps names of checked containers to their image names.
+	Containers          map[string]string
+	ImagesNewerVersions ImagesNewerVersions
+	// Failures holds images that could not be checked.
+	Failures []*ImageFailure
+}
+
+// Pipeline runs a single check: container discovery, downloading tags and comparing versions.
+type Pipeline struct {
+	config    Config
+	hosts     []DockerHost
+	apiClient *ApiClient
+	mirrors   []RegistryMirror
+	filters   ContainerFilters
+	tokens    *TokenStore
+	// platforms hold platforms of hosts by their names, when metadata or platforms of newer versions are checked.
+	platforms   map[string]Platform
+	interactive bool
+	metrics     *Metrics
+	// kubernetes is set when pods of Kubernetes cluster are checked instead of containers.
+	kubernetes kubernetes.Interface
+	// kubernetesNamespace is the namespace of the kubeconfig context, checked when no namespaces are given.
+	kubernetesNamespace string
+	// updaters are set when containers should be updated to newer versions, one for each host.
+	updaters []*Updater
+}
+
+// hostTargets are containers, local images or Swarm services of a host to check.
+type hostTargets struct {
+	host       DockerHost
+	containers []types.Container
+	imageNames []string
+	services   []swarm.Service
+}
+
+func NewPipeline(config Config, hosts []DockerHost, rateLimits *RateLimits, metrics *Metrics) (*Pipeline, error) {
+	apiClient, err := NewApiClient(config, rateLimits, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	registryMirrors, err := ParseRegistryMirrors(config.RegistryMirrors)
+	if err != nil {
+		return nil, err
+	}
+
+	containerFilters, err := ParseContainerFilters(config.Filter, config.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := &Pipeline{
+		config:      config,
+		hosts:       hosts,
+		apiClient:   apiClient,
+		mirrors:     registryMirrors,
+		filters:     containerFilters,
+		tokens:      NewTokenStore(),
+		interactive: !config.Watch,
+		metrics:     metrics,
+	}
+
+	// Updates, services and events are available only through Docker API.
+	if config.Apply || config.Swarm || config.Events {
+		for _, host := range hosts {
+			if host.cli == nil {
+				return nil, fmt.Errorf("host %s does not provide Docker API required to update containers, check services or watch events", host.Name)
+			}
+		}
+	}
+
+	if config.Apply {
+		// Digests of tags do not depend on platform, they are of image indexes for multi-platform images.
+		digests := NewMetadataDownloader(apiClient, pipeline.tokens, Platform{}).Digest
+		for _, host := range hosts {
+			pipeline.updaters = append(pipeline.updaters, NewUpdater(host, config, digests))
+		}
+	}
+
+	if config.Kubernetes {
+		pipeline.kubernetes, pipeline.kubernetesNamespace, err = NewKubernetesClient(config.Kubeconfig, time.Duration(config.Timeout)*time.Second)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Hosts may run on different platforms, Kubernetes nodes are expected to run on the local platform.
+	if config.Metadata || config.PlatformCheck != "" {
+		pipeline.platforms = make(map[string]Platform)
+		for _, host := range hosts {
+			cli := host.cli
+			if config.Kubernetes {
+				cli = nil
+			}
+			pipeline.platforms[host.Name], err = resolvePlatform(config, cli)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return pipeline, nil
+}
+
+// Run checks images of containers, including stopped ones with --all-containers, and local images not used by
+// any container with --images, on all hosts. Hosts which cannot be listed are skipped when multiple hosts are checked.
+func (p *Pipeline) Run() (CheckResult, error) {
+	if p.kubernetes != nil {
+		return p.runKubernetes()
+	}
+
+	var targets []hostTargets
+	found := false
+	for _, host := range p.hosts {
+		target, err := p.listTargets(host)
+		if err != nil {
+			if len(p.hosts) == 1 {
+				return CheckResult{}, err
+			}
+			fmt.Fprintf(os.Stderr, "Failed to list containers on %s, %v\n", host.Name, err)
+			continue
+		}
+
+		targets = append(targets, target)
+		found = found || len(target.containers) > 0 || len(target.imageNames) > 0 || len(target.services) > 0
+	}
+
+	if len(targets) == 0 {
+		return CheckResult{}, fmt.Errorf("failed to list containers on all Docker hosts")
+	}
+	if !found {
+		if p.config.Swarm {
+			fmt.Fprintln(os.Stderr, "No services")
+		} else if p.config.AllContainers {
+			fmt.Fprintln(os.Stderr, "No containers")
+		} else {
+			fmt.Fprintln(os.Stderr, "No running containers")
+		}
+	}
+
+	return p.runTargets(targets), nil
+}
+
+func (p *Pipeline) listTargets(host DockerHost) (hostTargets, error) {
+	if p.config.Swarm {
+		services, err := getServices(host.cli)
+		if err != nil {
+			return hostTargets{}, err
+		}
+
+		var networkNames map[string]string
+		if p.filters.usesNetworks() {
+			networkNames, err = getNetworkNames(host.cli)
+			if err != nil {
+				return hostTargets{}, err
+			}
+		}
+
+		target := hostTargets{host: host}
+		for _, service := range services {
+			if p.filters.Matches(serviceAsContainer(service, networkNames)) {
+				target.services = append(target.services, service)
+			}
+		}
+		return target, nil
+	}
+
+	containers, err := host.runtime.ListContainers(p.config.AllContainers, p.filters)
+	if err != nil {
+		return hostTargets{}, err
+	}
+	target := hostTargets{host: host, containers: containers}
+
+	if p.config.Images {
+		images, err := host.runtime.ListImages()
+		if err != nil {
+			return hostTargets{}, err
+		}
+		target.imageNames = localImageNames(images, target.containers)
+	}
+
+	return target, nil
+}
+
+// runKubernetes checks images of running pods grouped by workloads owning them.
+func (p *Pipeline) runKubernetes() (CheckResult, error) {
+	namespaces := p.config.KubernetesNamespaces
+	if len(namespaces) == 0 && p.kubernetesNamespace != "" {
+		namespaces = []string{p.kubernetesNamespace}
+	}
+
+	workloads, err := ListKubernetesWorkloads(p.kubernetes, namespaces, p.config.KubernetesSelector)
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	var filtered []KubernetesWorkload
+	for _, workload := range workloads {
+		if p.filters.Matches(workloadAsContainer(workload)) {
+			filtered = append(filtered, workload)
+		}
+	}
+	if len(filtered) == 0 {
+		fmt.Fprintln(os.Stderr, "No running pods")
+	}
+
+	result := p.run(func(versionChecker VersionChecker) {
+		if len(filtered) > 0 {
+			versionChecker.CheckWorkloadsImageTags(filtered)
+		}
+	})
+	for _, workload := range filtered {
+		result.Containers[workload.Key] = workload.Image
+	}
+
+	return result, nil
+}
+
+// RunContainers checks images of given containers of the first host. Unauthorized images can be authorized
+// interactively only outside of watch mode.
+func (p *Pipeline) RunContainers(containers []types.Container) CheckResult {
+	return p.runTargets([]hostTargets{{host: p.hosts[0], containers: containers}})
+}
+
+func (p *Pipeline) runTargets(targets []hostTargets) CheckResult {
+	result := p.run(func(versionChecker VersionChecker) {
+		for _, target := range targets {
+			if len(target.containers) > 0 {
+				versionChecker.CheckContainersImageTags(target.host.Name, target.containers)
+			}
+			if len(target.imageNames) > 0 {
+				versionChecker.CheckImagesTags(target.host.Name, target.imageNames)
+			}
+			if len(target.services) > 0 {
+				versionChecker.CheckServicesImageTags(target.host.Name, target.services)
+			}
+		}
+	})
+
+	for _, target := range targets {
+		for _, container := range target.containers {
+			result.Containers[containerKey(target.host.Name, containerName(container))] = container.Image
+		}
+		for _, service := range target.services {
+			result.Containers[containerKey(target.host.Name, service.Spec.Name)] = serviceImage(service)
+		}
+	}
+
+	return result
+}
+
+// RunImages checks images which are not run by containers, e.g. referenced in files.
+func (p *Pipeline) RunImages(imageNames []string) CheckResult {
+	return p.run(func(versionChecker VersionChecker) {
+		versionChecker.CheckImagesTags("", imageNames)
+	})
+}
+
+func (p *Pipeline) run(checkImagesTags func(versionChecker VersionChecker)) CheckResult {
+	tagDownloader := NewTagDownloader(p.apiClient, p.mirrors, p.tokens)
+
+	storage := &ImageStorage{}
+	checkImagesTags(NewVersionChecker(tagDownloader, storage))
+
+	if p.interactive {
+		authorizer := NewAuthorizer(tagDownloader, storage)
+		authorizer.Authorize()
+	}
+	// Images left unauthorized, also when authorization was quit, are reported as not checked.
+	for _, image := range storage.Unauthorized {
+		storage.addFailed(&ImageFailure{Image: image.Image, Reason: FailureUnauthorized, Err: fmt.Errorf("missing authorization")})
+	}
+
+	imagesNewerVersions := CheckImagesForNewerVersions(storage, p.config)
+
+	if p.config.Metadata || p.config.PlatformCheck != "" {
+		for i := range imagesNewerVersions {
+			inv := &imagesNewerVersions[i]
+			metadataDownloader := NewMetadataDownloader(p.apiClient, p.tokens, p.platform(inv.image.Host))
+
+			if p.config.Metadata {
+				metadataDownloader.DownloadImageMetadata(inv)
+			}
+			if p.config.PlatformCheck != "" {
+				metadataDownloader.CheckImagePlatforms(inv, p.config.PlatformCheck)
+			}
+		}
+	}
+
+	p.metrics.ObserveFailures(storage.Failed)
+
+	for _, updater := range p.updaters {
+		updater.Apply(imagesNewerVersions)
+	}
+
+	return CheckResult{Containers: make(map[string]string), ImagesNewerVersions: imagesNewerVersions, Failures: storage.Failed}
+}
+
+// platform returns platform of the host, images which do not run on any host, e.g. referenced in files, are checked
+// for the first host.
+func (p *Pipeline) platform(host string) Platform {
+	if platform, present := p.platforms[host]; present {
+		return platform
+	}
+	return p.platforms[p.hosts[0].Name]
+}
+
+// localImageNames returns sorted tags of local images, except images of given containers which are checked with them.
+// Images are matched by ID, as containers may reference them by ID or without tag, and by name when the runtime,
+// like containerd, does not report IDs.
+func localImageNames(images []types.ImageSummary, containers []types.Container) []string {
+	containerImageIds := make(map[string]bool)
+	containerImages := make(map[string]bool)
+	for _, container := range containers {
+		if container.ImageID != "" {
+			containerImageIds[container.ImageID] = true
+		}
+		containerImages[container.Image] = true
+	}
+
+	var imageNames []string
+	for _, image := range images {
+		if image.ID != "" && containerImageIds[image.ID] {
+			continue
+		}
+
+		for _, repoTag := range image.RepoTags {
+			if repoTag == "<none>:<none>" || containerImages[repoTag] {
+				continue
+			}
+			imageNames = append(imageNames, repoTag)
+		}
+	}
+
+	sort.Strings(imageNames)
+	return imageNames
+}
+
+func resolvePlatform(config Config, cli *client.Client) (Platform, error) {
+	if config.Platform != "" {
+		return ParsePlatform(config.Platform)
+	}
+	if cli == nil {
+		return LocalPlatform(), nil
+	}
+
+	platform, err := readDaemonPlatform(cli)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read platform from Docker daemon, %v\n", err)
+		return LocalPlatform(), nil
+	}
+
+	return platform, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/docker/docker/api/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLocalImageNamesSkipsContainerImages(t *testing.T) {
+	images := []types.ImageSummary{
+		{RepoTags: []string{"redis:7.0.0", "redis:7"}},
+		{RepoTags: []string{"<none>:<none>"}},
+		{RepoTags: nil},
+		{RepoTags: []string{"postgres:15.3"}},
+		{RepoTags: []string{"author/batch:1.2.0"}},
+		{ID: "sha256:4c0fdaa8", RepoTags: []string{"nginx:latest", "nginx:1.25.3"}},
+		{ID: "sha256:9a1e3c72", RepoTags: []string{"author/api:1.0.0"}},
+	}
+	containers := []types.Container{
+		{Image: "postgres:15.3"},
+		{Image: "nginx", ImageID: "sha256:4c0fdaa8"},
+		{Image: "9a1e3c72", ImageID: "sha256:9a1e3c72"},
+	}
+
+	imageNames := localImageNames(images, containers)
+
+	expected := []string{"author/batch:1.2.0", "redis:7", "redis:7.0.0"}
+	if !reflect.DeepEqual(expected, imageNames) {
+		t.Errorf("Should be %v, but is %v", expected, imageNames)
+	}
+}
+
+// captureStdout returns what the function writes to standard output.
+func captureStdout(t *testing.T, f func()) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan []byte)
+	go func() {
+		content, _ := ioutil.ReadAll(reader)
+		output <- content
+	}()
+
+	f()
+
+	_ = writer.Close()
+	return string(<-output)
+}
+
+func TestPipelineWritesOnlyReportToStdout(t *testing.T) {
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Limit", "100;w=21600")
+		w.Header().Set("RateLimit-Remaining", "76;w=21600")
+		if r.URL.Path == "/v2/library/image/tags/list" {
+			_, _ = w.Write([]byte(`{"name": "library/image", "tags": ["1.0.0", "1.1.0"]}`))
+		}
+	}))
+	defer mirror.Close()
+
+	config := Config{Timeout: 5, Watch: true, RegistryMirrors: []string{mirror.URL}}
+	rateLimits := NewRateLimits()
+	pipeline, err := NewPipeline(config, []DockerHost{{}}, rateLimits, NewMetrics(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, output := range []string{OutputJunit, OutputSarif} {
+		reporter, err := NewReporter(output, "", UpdateNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stdout := captureStdout(t, func() {
+			result := pipeline.RunImages([]string{"image:1.0.0", "image:latest"})
+			rateLimits.Print()
+			err = writeReport(reporter, NewReport(result, time.Now()), "")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var parsed interface{}
+		if output == OutputJunit {
+			err = xml.Unmarshal([]byte(stdout), &parsed)
+		} else {
+			err = json.Unmarshal([]byte(stdout), &parsed)
+		}
+		if err != nil {
+			t.Errorf("Should be valid %s report, but is %v:\n%s", output, err, stdout)
+		}
+	}
+}
+
+func TestPipelineChecksPlatformsOfEachHost(t *testing.T) {
+	server, apiClient, image := newTestRegistry(t)
+	defer server.Close()
+
+	pipeline := &Pipeline{
+		config:    Config{PlatformCheck: PlatformCheckFlag},
+		hosts:     []DockerHost{{Name: "web01"}, {Name: "web02"}},
+		apiClient: apiClient,
+		tokens:    NewTokenStore(),
+		platforms: map[string]Platform{"web01": {OS: "linux", Architecture: "ppc64le"}, "web02": {OS: "linux", Architecture: "arm64"}},
+	}
+
+	result := pipeline.run(func(versionChecker VersionChecker) {
+		for _, host := range []string{"web01", "web02"} {
+			hostImage := image
+			hostImage.Host = host
+			versionChecker.storage.addSuccessful(&ImageTags{Image: hostImage, Tags: []string{"1.0.0", "1.1.0"}})
+		}
+	})
+
+	unavailable := make(map[string]bool)
+	for _, inv := range result.ImagesNewerVersions {
+		unavailable[inv.image.Host] = inv.unavailable["1.1.0"]
+	}
+	if expected := map[string]bool{"web01": true, "web02": false}; !reflect.DeepEqual(expected, unavailable) {
+		t.Errorf("Should be %v, but is %v", expected, unavailable)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitHeader          = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+
+	throttleThreshold = 0.1
+	throttleStep      = 500 * time.Millisecond
+	maxThrottleDelay  = 10 * time.Second
+)
+
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Window    time.Duration
+	// Reset is when exhausted requests become available again, zero when it is not known.
+	Reset time.Time
+}
+
+type RateLimits struct {
+	mutex  sync.Mutex
+	limits map[string]*RateLimit
+}
+
+func NewRateLimits() *RateLimits {
+	return &RateLimits{limits: make(map[string]*RateLimit)}
+}
+
+func (rl *RateLimits) Update(registry string, header http.Header) {
+	limit, window, err := parseRateLimitHeader(header.Get(rateLimitHeader))
+	if err != nil {
+		return
+	}
+
+	remaining, _, err := parseRateLimitHeader(header.Get(rateLimitRemainingHeader))
+	if err != nil {
+		return
+	}
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	rl.limits[registry] = &RateLimit{Limit: limit, Remaining: remaining, Window: window, Reset: rateLimitReset(header, limit, window)}
+}
+
+// rateLimitReset returns when exhausted requests become available again, given in seconds by RateLimit-Reset
+// header. Docker Hub does not send it, so the time a single request is replenished over the window is used
+// instead, e.g. 216 seconds for 100 requests per 6 hours.
+func rateLimitReset(header http.Header, limit int, window time.Duration) time.Time {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header.Get(rateLimitResetHeader))); err == nil {
+		return time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	if window > 0 && limit > 0 {
+		return time.Now().Add(window / time.Duration(limit))
+	}
+	return time.Time{}
+}
+
+func (rl *RateLimits) Wait(registry string) {
+	delay := rl.throttleDelay(registry)
+	if delay == 0 {
+		return
+	}
+
+	log.Debugf("Approaching rate limit of %s, waiting %v\n", registry, delay)
+	time.Sleep(delay)
+}
+
+func (rl *RateLimits) throttleDelay(registry string) time.Duration {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	limit, present := rl.limits[registry]
+	if !present || limit.Limit == 0 {
+		return 0
+	}
+	// Exhausted requests are waited for until the reset, capped so that the check keeps going.
+	if limit.Remaining <= 0 {
+		if limit.Reset.IsZero() {
+			return maxThrottleDelay
+		}
+		return capThrottleDelay(time.Until(limit.Reset))
+	}
+
+	threshold := int(float64(limit.Limit) * throttleThreshold)
+	if limit.Remaining > threshold {
+		return 0
+	}
+
+	return capThrottleDelay(time.Duration(threshold-limit.Remaining+1) * throttleStep)
+}
+
+func capThrottleDelay(delay time.Duration) time.Duration {
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxThrottleDelay {
+		return maxThrottleDelay
+	}
+	return delay
+}
+
+func (rl *RateLimits) Print() {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	var registries []string
+	for registry := range rl.limits {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	for _, registry := range registries {
+		limit := rl.limits[registry]
+		fmt.Fprintf(os.Stderr, "Rate limit of %s: %d of %d requests remaining", registry, limit.Remaining, limit.Limit)
+		if limit.Window > 0 {
+			fmt.Fprintf(os.Stderr, " per %v", limit.Window)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// parseRateLimitHeader parses values like "100;w=21600" used by Docker Hub.
+func parseRateLimitHeader(value string) (int, time.Duration, error) {
+	if value == "" {
+		return 0, 0, fmt.Errorf("empty rate limit header")
+	}
+
+	parts := strings.Split(value, ";")
+
+	count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var window time.Duration
+	for _, param := range parts[1:] {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "w=") {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(strings.TrimPrefix(param, "w="))
+		if err != nil {
+			return 0, 0, err
+		}
+		window = time.Duration(seconds) * time.Second
+	}
+
+	return count, window, nil
+}
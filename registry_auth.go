@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// dockerHubAuthAddress is the address Docker CLI stores credentials of Docker Hub under.
+	dockerHubAuthAddress = "https://index.docker.io/v1/"
+	dockerHubAuthHost    = "index.docker.io"
+
+	credentialHelperTokenUser = "<token>"
+)
+
+// DockerCredentials reads credentials stored by docker login in Docker config, either directly or in credential
+// helpers, so that the daemon can pull private images on behalf of dvchk.
+type DockerCredentials struct {
+	configDir string
+	// credentialHelper runs docker-credential-<helper> get for given server address and returns its output.
+	credentialHelper func(helper string, serverAddress string) ([]byte, error)
+}
+
+type dockerConfigFile struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+type credentialHelperOutput struct {
+	Username string
+	Secret   string
+}
+
+func NewDockerCredentials() DockerCredentials {
+	return DockerCredentials{
+		configDir: dockerConfigDir(),
+		credentialHelper: func(helper string, serverAddress string) ([]byte, error) {
+			cmd := exec.Command("docker-credential-"+helper, "get")
+			cmd.Stdin = strings.NewReader(serverAddress)
+			return cmd.Output()
+		},
+	}
+}
+
+// RegistryAuth returns credentials of the registry encoded for X-Registry-Auth header of Docker API, it is empty
+// when there are no credentials.
+func (dc DockerCredentials) RegistryAuth(registry string) (string, error) {
+	authConfig, err := dc.read(registry)
+	if err != nil || authConfig == nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(authConfig)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(encoded), nil
+}
+
+func (dc DockerCredentials) read(registry string) (*types.AuthConfig, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dc.configDir, "config.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config dockerConfigFile
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Docker config, %v", err)
+	}
+
+	host := authHost(registry)
+	serverAddress := host
+	if host == dockerHubAuthHost {
+		serverAddress = dockerHubAuthAddress
+	}
+
+	helper := config.CredsStore
+	for address, addressHelper := range config.CredHelpers {
+		if authHost(address) == host {
+			helper = addressHelper
+		}
+	}
+	if helper != "" {
+		return dc.readCredentialHelper(helper, serverAddress)
+	}
+
+	for address, auth := range config.Auths {
+		if authHost(address) == host {
+			return decodeDockerConfigAuth(auth, serverAddress)
+		}
+	}
+	return nil, nil
+}
+
+func (dc DockerCredentials) readCredentialHelper(helper string, serverAddress string) (*types.AuthConfig, error) {
+	output, err := dc.credentialHelper(helper, serverAddress)
+	if err != nil {
+		// Helpers report missing credentials on standard output.
+		if strings.Contains(string(output), "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("credential helper %s failed, %v", helper, err)
+	}
+
+	var credentials credentialHelperOutput
+	err = json.Unmarshal(output, &credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse output of credential helper %s, %v", helper, err)
+	}
+
+	if credentials.Username == credentialHelperTokenUser {
+		return &types.AuthConfig{IdentityToken: credentials.Secret, ServerAddress: serverAddress}, nil
+	}
+	return &types.AuthConfig{Username: credentials.Username, Password: credentials.Secret, ServerAddress: serverAddress}, nil
+}
+
+func decodeDockerConfigAuth(auth dockerConfigAuth, serverAddress string) (*types.AuthConfig, error) {
+	authConfig := &types.AuthConfig{Username: auth.Username, Password: auth.Password, IdentityToken: auth.IdentityToken, ServerAddress: serverAddress}
+
+	if auth.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credentials of %s, %v", serverAddress, err)
+		}
+
+		split := strings.SplitN(string(decoded), ":", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid credentials of %s, expected username:password", serverAddress)
+		}
+		authConfig.Username, authConfig.Password = split[0], split[1]
+	}
+
+	return authConfig, nil
+}
+
+// authHost returns host of the registry the way Docker CLI matches stored credentials, which are stored under
+// addresses like https://registry.com/v1/, and under index.docker.io for Docker Hub.
+func authHost(address string) string {
+	address = strings.TrimPrefix(address, "http://")
+	address = strings.TrimPrefix(address, "https://")
+	if i := strings.Index(address, "/"); i >= 0 {
+		address = address[:i]
+	}
+
+	if address == defaultRegistry || address == "docker.io" {
+		return dockerHubAuthHost
+	}
+	return address
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestDockerCredentials(t *testing.T, config string) (DockerCredentials, func()) {
+	dir, err := ioutil.TempDir("", "dvchk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := DockerCredentials{configDir: dir, credentialHelper: func(helper string, serverAddress string) ([]byte, error) {
+		if helper == "ecr-login" && serverAddress == "123456789012.dkr.ecr.eu-west-1.amazonaws.com" {
+			return []byte(`{"ServerURL":"123456789012.dkr.ecr.eu-west-1.amazonaws.com","Username":"AWS","Secret":"ecr-token"}`), nil
+		}
+		return []byte("credentials not found in native keychain"), fmt.Errorf("exit status 1")
+	}}
+	return credentials, func() { os.RemoveAll(dir) }
+}
+
+func decodeRegistryAuth(t *testing.T, encoded string) types.AuthConfig {
+	var authConfig types.AuthConfig
+	decoded, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal(decoded, &authConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authConfig
+}
+
+func TestDockerCredentialsRegistryAuth(t *testing.T) {
+	credentials, cleanup := newTestDockerCredentials(t, `{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("hubuser:hubpass"))+`"},
+			"registry.com": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("user:pa:ss"))+`"}
+		},
+		"credHelpers": {"123456789012.dkr.ecr.eu-west-1.amazonaws.com": "ecr-login"}
+	}`)
+	defer cleanup()
+
+	cases := map[string]types.AuthConfig{
+		defaultRegistry: {Username: "hubuser", Password: "hubpass", ServerAddress: dockerHubAuthAddress},
+		"registry.com":  {Username: "user", Password: "pa:ss", ServerAddress: "registry.com"},
+		"123456789012.dkr.ecr.eu-west-1.amazonaws.com": {Username: "AWS", Password: "ecr-token", ServerAddress: "123456789012.dkr.ecr.eu-west-1.amazonaws.com"},
+	}
+
+	for registry, expected := range cases {
+		encoded, err := credentials.RegistryAuth(registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if authConfig := decodeRegistryAuth(t, encoded); !reflect.DeepEqual(expected, authConfig) {
+			t.Errorf("%s should be %v, but is %v", registry, expected, authConfig)
+		}
+	}
+
+	encoded, err := credentials.RegistryAuth("localhost:5000")
+	if err != nil || encoded != "" {
+		t.Errorf("Should be no credentials, but is %s, %v", encoded, err)
+	}
+}
+
+func TestDockerCredentialsIgnoreMissingCredentialsOfStore(t *testing.T) {
+	credentials, cleanup := newTestDockerCredentials(t, `{"credsStore": "desktop"}`)
+	defer cleanup()
+
+	encoded, err := credentials.RegistryAuth("registry.com")
+	if err != nil || encoded != "" {
+		t.Errorf("Should be no credentials, but is %s, %v", encoded, err)
+	}
+}
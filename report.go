@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-version"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+)
+
+const (
+	OutputText     = "text"
+	OutputTable    = "table"
+	OutputMarkdown = "markdown"
+	OutputCsv      = "csv"
+	OutputJunit    = "junit"
+	OutputSarif    = "sarif"
+	OutputHtml     = "html"
+)
+
+// Report is the result model given to reporters and to templates of --format.
+type Report struct {
+	CheckedAt time.Time       `json:"checkedAt"`
+	Images    []ReportImage   `json:"images"`
+	Failures  []ReportFailure `json:"failures"`
+	Summary   ReportSummary   `json:"summary"`
+}
+
+type ReportImage struct {
+	Image         string   `json:"image"`
+	Container     string   `json:"container"`
+	Host          string   `json:"host,omitempty"`
+	Current       string   `json:"current"`
+	NewerVersions []string `json:"newer"`
+	Level         string   `json:"level"`
+	// State is either up_to_date or outdated.
+	State string `json:"state"`
+	// LatestPatch, LatestMinor and LatestMajor are the highest newer versions changing given segment.
+	LatestPatch string `json:"latestPatch"`
+	LatestMinor string `json:"latestMinor"`
+	LatestMajor string `json:"latestMajor"`
+	// Description is the sentence printed by text output, including metadata of newer versions.
+	Description string `json:"description"`
+	// Sources are positions in files referencing the image, if files were checked.
+	Sources []ReportSource `json:"sources,omitempty"`
+}
+
+type ReportSource struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+}
+
+type ReportFailure struct {
+	Image     string `json:"image"`
+	Container string `json:"container"`
+	Host      string `json:"host,omitempty"`
+	// State is either skipped, unauthorized or error.
+	State  string `json:"state"`
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+}
+
+// ReportSummary counts images by their final state.
+type ReportSummary struct {
+	Total        int `json:"total"`
+	UpToDate     int `json:"upToDate"`
+	Outdated     int `json:"outdated"`
+	Skipped      int `json:"skipped"`
+	Unauthorized int `json:"unauthorized"`
+	Errors       int `json:"errors"`
+}
+
+func (rs *ReportSummary) add(state string) {
+	rs.Total++
+
+	switch state {
+	case StateUpToDate:
+		rs.UpToDate++
+	case StateOutdated:
+		rs.Outdated++
+	case StateSkipped:
+		rs.Skipped++
+	case StateUnauthorized:
+		rs.Unauthorized++
+	default:
+		rs.Errors++
+	}
+}
+
+func (rs ReportSummary) String() string {
+	return fmt.Sprintf("Checked %d images: %d outdated, %d up to date, %d skipped, %d unauthorized, %d failed",
+		rs.Total, rs.Outdated, rs.UpToDate, rs.Skipped, rs.Unauthorized, rs.Errors)
+}
+
+func NewReport(result CheckResult, checkedAt time.Time) Report {
+	report := Report{CheckedAt: checkedAt, Images: []ReportImage{}, Failures: []ReportFailure{}}
+
+	for _, inv := range result.ImagesNewerVersions {
+		image := ReportImage{
+			Image:         inv.imageName,
+			Container:     inv.image.Container,
+			Host:          inv.image.Host,
+			Current:       inv.image.Tag,
+			NewerVersions: append([]string{}, inv.newerVersions...),
+			Level:         inv.Level().String(),
+			Description:   inv.Describe(),
+			State:         StateUpToDate,
+		}
+		if len(inv.newerVersions) > 0 {
+			image.State = StateOutdated
+		}
+		if image.Host != "" {
+			image.Description = fmt.Sprintf("%s: %s", image.Host, image.Description)
+		}
+		image.LatestPatch = latestVersion(inv, UpdatePatch)
+		image.LatestMinor = latestVersion(inv, UpdateMinor)
+		image.LatestMajor = latestVersion(inv, UpdateMajor)
+
+		report.Images = append(report.Images, image)
+		report.Summary.add(image.State)
+	}
+
+	for _, failure := range result.Failures {
+		report.Failures = append(report.Failures, ReportFailure{
+			Image:     failure.Image.LocalFullName,
+			Container: failure.Image.Container,
+			Host:      failure.Image.Host,
+			State:     failure.State(),
+			Reason:    failure.Reason,
+			Error:     fmt.Sprint(failure.Err),
+		})
+		report.Summary.add(failure.State())
+	}
+
+	return report
+}
+
+// AttachSources adds positions of file references to images.
+func (r *Report) AttachSources(references []FileReference) {
+	for i := range r.Images {
+		for _, reference := range references {
+			if reference.Image == r.Images[i].Image {
+				r.Images[i].Sources = append(r.Images[i].Sources, ReportSource{Path: reference.Path, Line: reference.Line})
+			}
+		}
+	}
+}
+
+// ContainerKey returns name of the container prefixed by its host, when multiple hosts are checked.
+func (ri ReportImage) ContainerKey() string {
+	return containerKey(ri.Host, ri.Container)
+}
+
+func (rf ReportFailure) ContainerKey() string {
+	return containerKey(rf.Host, rf.Container)
+}
+
+// Exceeds tells whether the image has updates above the allowed level.
+func (ri ReportImage) Exceeds(allowed UpdateLevel) bool {
+	level, _ := ParseUpdateLevel(ri.Level)
+	return level > allowed
+}
+
+// latestVersion returns the highest newer version of exactly given update level.
+func latestVersion(inv ImageNewerVersions, level UpdateLevel) string {
+	var latest *version.Version
+	var latestTag string
+
+	for _, newerVersion := range inv.newerVersions {
+		if GetUpdateLevel(inv.image.Tag, newerVersion) != level {
+			continue
+		}
+
+		v, err := version.NewSemver(newerVersion)
+		if err == nil && (latest == nil || v.GreaterThan(latest)) {
+			latest = v
+			latestTag = newerVersion
+		}
+	}
+
+	return latestTag
+}
+
+type Reporter interface {
+	Report(w io.Writer, report Report) error
+}
+
+// NewReporter creates reporter of given output format, or of the template if one is given. CI formats report
+// images with updates above the allowed level as failures.
+func NewReporter(output string, format string, allowed UpdateLevel) (Reporter, error) {
+	if format != "" {
+		return NewTemplateReporter(format)
+	}
+
+	switch output {
+	case OutputText:
+		return TextReporter{}, nil
+	case OutputTable:
+		return TableReporter{}, nil
+	case OutputMarkdown:
+		return MarkdownReporter{}, nil
+	case OutputCsv:
+		return CsvReporter{}, nil
+	case OutputJunit:
+		return JunitReporter{allowed: allowed}, nil
+	case OutputSarif:
+		return SarifReporter{allowed: allowed}, nil
+	case OutputHtml:
+		return HtmlReporter{}, nil
+	default:
+		return nil, fmt.Errorf("%s is invalid output format", output)
+	}
+}
+
+// TextReporter prints a sentence per image, like the check always did, followed by the summary.
+type TextReporter struct{}
+
+func (TextReporter) Report(w io.Writer, report Report) error {
+	for _, image := range report.Images {
+		_, err := fmt.Fprintln(w, image.Description)
+		if err != nil {
+			return err
+		}
+	}
+	return writeSummary(w, report)
+}
+
+// writeSummary prints images that were not checked with their reasons, and counts of images by state.
+func writeSummary(w io.Writer, report Report) error {
+	if len(report.Failures) > 0 {
+		fmt.Fprintln(w, "\nNot checked:")
+	}
+	for _, failure := range report.Failures {
+		name := failure.Image
+		if failure.Container != "" {
+			name = fmt.Sprintf("%s [%s]", failure.Image, failure.ContainerKey())
+		} else if failure.Host != "" {
+			name = fmt.Sprintf("%s on %s", failure.Image, failure.Host)
+		}
+		fmt.Fprintf(w, "%s: %s (%s), %s\n", name, failure.State, failure.Reason, failure.Error)
+	}
+
+	_, err := fmt.Fprintf(w, "\n%s\n", report.Summary)
+	return err
+}
+
+var reportColumns = []string{"Container", "Image", "Current", "Patch", "Minor", "Major"}
+
+func (ri ReportImage) columns(empty string) []string {
+	columns := []string{ri.ContainerKey(), ri.Image, ri.Current, ri.LatestPatch, ri.LatestMinor, ri.LatestMajor}
+	for i, column := range columns {
+		if column == "" {
+			columns[i] = empty
+		}
+	}
+	return columns
+}
+
+// TableReporter prints aligned columns with the latest patch, minor and major version of each image.
+type TableReporter struct{}
+
+func (TableReporter) Report(w io.Writer, report Report) error {
+	table := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+
+	fmt.Fprintln(table, strings.ToUpper(strings.Join(reportColumns, "\t")))
+	for _, image := range report.Images {
+		fmt.Fprintln(table, strings.Join(image.columns("-"), "\t"))
+	}
+
+	err := table.Flush()
+	if err != nil {
+		return err
+	}
+	return writeSummary(w, report)
+}
+
+// MarkdownReporter prints a table to paste into merge requests and wiki pages.
+type MarkdownReporter struct{}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`)
+
+func (MarkdownReporter) Report(w io.Writer, report Report) error {
+	var separators []string
+	for range reportColumns {
+		separators = append(separators, "---")
+	}
+
+	fmt.Fprintf(w, "| %s |\n", strings.Join(reportColumns, " | "))
+	fmt.Fprintf(w, "| %s |\n", strings.Join(separators, " | "))
+
+	for _, image := range report.Images {
+		columns := image.columns("")
+		for i, column := range columns {
+			if column != "" && i > 0 {
+				column = "`" + column + "`"
+			}
+			columns[i] = markdownEscaper.Replace(column)
+		}
+
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(columns, " | "))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CsvReporter prints the same columns as table, with all newer versions separated by spaces in the last column.
+type CsvReporter struct{}
+
+func (CsvReporter) Report(w io.Writer, report Report) error {
+	writer := csv.NewWriter(w)
+
+	header := append([]string{}, reportColumns...)
+	header = append(header, "Newer")
+	for i := range header {
+		header[i] = strings.ToLower(header[i])
+	}
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range report.Images {
+		err = writer.Write(append(image.columns(""), strings.Join(image.NewerVersions, " ")))
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// TemplateReporter renders the report with a Go template.
+type TemplateReporter struct {
+	template *template.Template
+}
+
+var reportTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"json": func(value interface{}) (string, error) {
+		bytes, err := json.Marshal(value)
+		return string(bytes), err
+	},
+}
+
+func NewTemplateReporter(format string) (TemplateReporter, error) {
+	reportTemplate, err := template.New("format").Funcs(reportTemplateFuncs).Parse(format)
+	if err != nil {
+		return TemplateReporter{}, fmt.Errorf("invalid format, %v", err)
+	}
+
+	return TemplateReporter{template: reportTemplate}, nil
+}
+
+func (tr TemplateReporter) Report(w io.Writer, report Report) error {
+	var output bytes.Buffer
+	err := tr.template.Execute(&output, report)
+	if err != nil {
+		return err
+	}
+
+	// Templates given on command line usually do not end with a new line.
+	if !strings.HasSuffix(output.String(), "\n") {
+		output.WriteString("\n")
+	}
+
+	_, err = w.Write(output.Bytes())
+	return err
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testReportResult = CheckResult{
+	ImagesNewerVersions: ImagesNewerVersions{
+		newTestNewerVersions("author/api:1.0.0", "api", "1.0.1", "1.0.2", "1.1.0", "2.0.0"),
+		newTestNewerVersions("postgres:15.3", "db"),
+	},
+	Failures: []*ImageFailure{{Image: Image{LocalFullName: "nginx:latest", Container: "web"}, Reason: FailureUnsupportedTag, Err: fmt.Errorf("Malformed version: latest")}},
+}
+
+var testReportTime = time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC)
+
+func TestNewReport(t *testing.T) {
+	report := NewReport(testReportResult, testReportTime)
+
+	expected := Report{
+		CheckedAt: testReportTime,
+		Images: []ReportImage{
+			{
+				Image: "author/api:1.0.0", Container: "api", Current: "1.0.0", NewerVersions: []string{"1.0.1", "1.0.2", "1.1.0", "2.0.0"},
+				Level: "major", State: StateOutdated, LatestPatch: "1.0.2", LatestMinor: "1.1.0", LatestMajor: "2.0.0",
+				Description: "There are new versions of author/api:1.0.0! Newer versions: [1.0.1 1.0.2 1.1.0 2.0.0]",
+			},
+			{
+				Image: "postgres:15.3", Container: "db", Current: "15.3", NewerVersions: []string{}, Level: "none", State: StateUpToDate,
+				Description: "postgres:15.3 is up to date",
+			},
+		},
+		Failures: []ReportFailure{{Image: "nginx:latest", Container: "web", State: StateSkipped, Reason: FailureUnsupportedTag, Error: "Malformed version: latest"}},
+		Summary:  ReportSummary{Total: 3, UpToDate: 1, Outdated: 1, Skipped: 1},
+	}
+	if !reflect.DeepEqual(expected, report) {
+		t.Errorf("Should be %+v, but is %+v", expected, report)
+	}
+}
+
+func TestReportSummaryCountsFailuresByState(t *testing.T) {
+	result := CheckResult{Failures: []*ImageFailure{
+		{Image: Image{LocalFullName: "nginx"}, Reason: FailureUnsupportedTag, Err: fmt.Errorf("not specified tag")},
+		{Image: Image{LocalFullName: "a/b/c/d:1.0"}, Reason: FailureInvalidImage, Err: fmt.Errorf("invalid")},
+		{Image: Image{LocalFullName: "private.io/app:1.0"}, Reason: FailureUnauthorized, Err: fmt.Errorf("missing authorization")},
+		{Image: Image{LocalFullName: "redis:7.0"}, Reason: FailureDownload, Err: fmt.Errorf("timeout")},
+		{Image: Image{LocalFullName: "mysql:8.0"}, Reason: FailureComparison, Err: fmt.Errorf("malformed constraint")},
+	}}
+
+	summary := NewReport(result, testReportTime).Summary
+
+	expected := ReportSummary{Total: 5, Skipped: 2, Unauthorized: 1, Errors: 2}
+	if summary != expected {
+		t.Errorf("Should be %+v, but is %+v", expected, summary)
+	}
+}
+
+func TestReporters(t *testing.T) {
+	cases := []struct {
+		output   string
+		format   string
+		expected string
+	}{
+		{OutputText, "", `There are new versions of author/api:1.0.0! Newer versions: [1.0.1 1.0.2 1.1.0 2.0.0]
+postgres:15.3 is up to date
+
+Not checked:
+nginx:latest [web]: skipped (unsupported_tag), Malformed version: latest
+
+Checked 3 images: 1 outdated, 1 up to date, 1 skipped, 0 unauthorized, 0 failed
+`},
+		{OutputTable, "", `CONTAINER   IMAGE              CURRENT   PATCH   MINOR   MAJOR
+api         author/api:1.0.0   1.0.0     1.0.2   1.1.0   2.0.0
+db          postgres:15.3      15.3      -       -       -
+
+Not checked:
+nginx:latest [web]: skipped (unsupported_tag), Malformed version: latest
+
+Checked 3 images: 1 outdated, 1 up to date, 1 skipped, 0 unauthorized, 0 failed
+`},
+		{OutputMarkdown, "", "| Container | Image | Current | Patch | Minor | Major |\n" +
+			"| --- | --- | --- | --- | --- | --- |\n" +
+			"| api | `author/api:1.0.0` | `1.0.0` | `1.0.2` | `1.1.0` | `2.0.0` |\n" +
+			"| db | `postgres:15.3` | `15.3` |  |  |  |\n"},
+		{OutputCsv, "", `container,image,current,patch,minor,major,newer
+api,author/api:1.0.0,1.0.0,1.0.2,1.1.0,2.0.0,1.0.1 1.0.2 1.1.0 2.0.0
+db,postgres:15.3,15.3,,,,
+`},
+		{OutputText, `{{ range .Images }}{{ .Container }}={{ .Level }};{{ end }}{{ len .Failures }}`, "api=major;db=none;1\n"},
+	}
+
+	for _, c := range cases {
+		reporter, err := NewReporter(c.output, c.format, UpdateNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var output bytes.Buffer
+		err = reporter.Report(&output, NewReport(testReportResult, testReportTime))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output.String() != c.expected {
+			t.Errorf("%s should be\n%s\nbut is\n%s", c.output, c.expected, output.String())
+		}
+	}
+}
+
+func TestNewReporterFailsOnInvalidFormat(t *testing.T) {
+	if _, err := NewReporter("yaml", "", UpdateNone); err == nil {
+		t.Error("Should fail for yaml output")
+	}
+	if _, err := NewReporter(OutputText, "{{ .Images", UpdateNone); err == nil {
+		t.Error("Should fail for invalid template")
+	}
+}
+
+func TestJunitReporter(t *testing.T) {
+	reporter, _ := NewReporter(OutputJunit, "", UpdatePatch)
+
+	result := testReportResult
+	result.ImagesNewerVersions = append(result.ImagesNewerVersions, newTestNewerVersions("redis:7.0.0", "cache", "7.0.1"))
+
+	var output bytes.Buffer
+	err := reporter.Report(&output, NewReport(result, testReportTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="dvchk" tests="4" failures="1" errors="0" skipped="1">
+    <testcase classname="api" name="author/api:1.0.0">
+      <failure message="major update is available">There are new versions of author/api:1.0.0! Newer versions: [1.0.1 1.0.2 1.1.0 2.0.0]</failure>
+    </testcase>
+    <testcase classname="db" name="postgres:15.3"></testcase>
+    <testcase classname="cache" name="redis:7.0.0"></testcase>
+    <testcase classname="web" name="nginx:latest">
+      <skipped message="unsupported_tag">Malformed version: latest</skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if output.String() != expected {
+		t.Errorf("Should be\n%s\nbut is\n%s", expected, output.String())
+	}
+}
+
+func TestSarifReporterPointsAtFiles(t *testing.T) {
+	reporter, _ := NewReporter(OutputSarif, "", UpdatePatch)
+
+	result := CheckResult{ImagesNewerVersions: ImagesNewerVersions{
+		newTestNewerVersions("postgres:15.3", "", "15.4"),
+		newTestNewerVersions("redis:7.0.0", "", "7.0.1"),
+	}}
+	report := NewReport(result, testReportTime)
+	report.AttachSources([]FileReference{
+		{Path: "deploy/docker-compose.yml", Line: 3, Image: "postgres:15.3"},
+		{Path: "deploy/docker-compose.yml", Line: 5, Image: "redis:7.0.0"},
+	})
+
+	var output bytes.Buffer
+	err := reporter.Report(&output, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sarif sarifLog
+	err = json.Unmarshal(output.Bytes(), &sarif)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []sarifResult{{
+		RuleId:  "minor-update",
+		Level:   "warning",
+		Message: sarifMessage{Text: "postgres:15.3 can be updated to 15.4"},
+		Locations: []sarifLocation{{PhysicalLocation: &sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{Uri: "deploy/docker-compose.yml"},
+			Region:           sarifRegion{StartLine: 3},
+		}}},
+	}}
+	if sarif.Version != sarifVersion || len(sarif.Runs) != 1 || !reflect.DeepEqual(expected, sarif.Runs[0].Results) {
+		t.Errorf("Should be %+v, but is %s", expected, output.String())
+	}
+}
+
+func TestHtmlReporter(t *testing.T) {
+	reporter, _ := NewReporter(OutputHtml, "", UpdateNone)
+
+	var output bytes.Buffer
+	err := reporter.Report(&output, NewReport(testReportResult, testReportTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := output.String()
+	for _, expected := range []string{
+		"Checked at 2026-10-18 06:00:00 UTC",
+		`<td><code>author/api:1.0.0</code></td>`,
+		`<span class="badge major">major</span>`,
+		`<span class="badge none">up to date</span>`,
+		`<td>unsupported_tag</td><td>Malformed version: latest</td>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Should contain %s, but is %s", expected, html)
+		}
+	}
+
+	if strings.Contains(html, "<link") || strings.Contains(html, "src=") {
+		t.Errorf("Should not reference external assets, but is %s", html)
+	}
+}
+
+func TestReportLabelsHosts(t *testing.T) {
+	web01 := newTestNewerVersions("author/api:1.0.0", "api", "1.1.0")
+	web01.image.Host = "web01"
+	web02 := newTestNewerVersions("author/api:1.0.0", "api")
+	web02.image.Host = "web02"
+
+	reporter, _ := NewReporter(OutputTable, "", UpdateNone)
+
+	var output bytes.Buffer
+	err := reporter.Report(&output, NewReport(CheckResult{ImagesNewerVersions: ImagesNewerVersions{web01, web02}}, testReportTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `CONTAINER   IMAGE              CURRENT   PATCH   MINOR   MAJOR
+web01/api   author/api:1.0.0   1.0.0     -       1.1.0   -
+web02/api   author/api:1.0.0   1.0.0     -       -       -
+
+Checked 2 images: 1 outdated, 1 up to date, 0 skipped, 0 unauthorized, 0 failed
+`
+	if output.String() != expected {
+		t.Errorf("Should be\n%s\nbut is\n%s", expected, output.String())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Results describe the latest check of containers, they are served by the HTTP server.
+type Results struct {
+	CheckedAt  time.Time         `json:"checkedAt"`
+	Containers []ContainerResult `json:"containers"`
+}
+
+type ContainerResult struct {
+	Container  string `json:"container"`
+	Image      string `json:"image"`
+	CurrentTag string `json:"current"`
+	// Checked is false when the image could not be checked, e.g. because its tag is not a version.
+	Checked       bool     `json:"checked"`
+	NewerVersions []string `json:"newer"`
+	Level         string   `json:"level"`
+}
+
+func NewResults(result CheckResult, checkedAt time.Time) Results {
+	results := Results{CheckedAt: checkedAt, Containers: []ContainerResult{}}
+
+	checked := make(map[string]ImageNewerVersions)
+	for _, inv := range result.ImagesNewerVersions {
+		checked[inv.image.ContainerKey()] = inv
+	}
+
+	for _, container := range sortedContainers(result.Containers) {
+		containerResult := ContainerResult{Container: container, Image: result.Containers[container], NewerVersions: []string{}}
+
+		if inv, present := checked[container]; present {
+			containerResult.Checked = true
+			containerResult.CurrentTag = inv.image.Tag
+			containerResult.NewerVersions = append(containerResult.NewerVersions, inv.newerVersions...)
+			containerResult.Level = inv.Level().String()
+		} else if image, err := getImageDetails(containerResult.Image); err == nil {
+			containerResult.CurrentTag = image.Tag
+		}
+
+		results.Containers = append(results.Containers, containerResult)
+	}
+
+	return results
+}
+
+// ResultStore keeps the latest results to be read concurrently with checks.
+type ResultStore struct {
+	mutex   sync.RWMutex
+	results *Results
+}
+
+func (rs *ResultStore) Set(result CheckResult, checkedAt time.Time) {
+	results := NewResults(result, checkedAt)
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	rs.results = &results
+}
+
+// Get returns nil until the first check finishes.
+func (rs *ResultStore) Get() *Results {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+	return rs.results
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"golang.org/x/net/context"
+	"os"
+	"path/filepath"
+)
+
+const defaultDockerSocket = "/var/run/docker.sock"
+
+// ContainerRuntime lists containers and images of a host, e.g. of Docker, Podman or containerd.
+type ContainerRuntime interface {
+	// ListContainers returns running containers, or all containers, which match the filters.
+	ListContainers(all bool, containerFilters ContainerFilters) ([]types.Container, error)
+	// ListImages returns images present on the host.
+	ListImages() ([]types.ImageSummary, error)
+}
+
+// DockerRuntime lists containers through Docker API, which is provided by Podman too.
+type DockerRuntime struct {
+	cli *client.Client
+}
+
+func (dr DockerRuntime) ListContainers(all bool, containerFilters ContainerFilters) ([]types.Container, error) {
+	options := types.ContainerListOptions{All: all, Filters: containerFilters.Args()}
+	containers, err := dr.cli.ContainerList(context.Background(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return containerFilters.Filter(containers), nil
+}
+
+func (dr DockerRuntime) ListImages() ([]types.ImageSummary, error) {
+	return dr.cli.ImageList(context.Background(), types.ImageListOptions{})
+}
+
+// newEnvClient connects to the host from DOCKER_HOST, or to Podman socket when neither DOCKER_HOST nor Docker
+// socket is present.
+func newEnvClient() (*client.Client, error) {
+	if os.Getenv("DOCKER_HOST") == "" && !fileExists(defaultDockerSocket) {
+		if socket := findSocket(podmanSockets()); socket != "" {
+			return client.NewClient("unix://"+socket, dockerApiVersion(), nil, nil)
+		}
+	}
+
+	return client.NewEnvClient()
+}
+
+// podmanSockets returns sockets of rootless and rootful Podman API service.
+func podmanSockets() []string {
+	var sockets []string
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		sockets = append(sockets, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+	return append(sockets, "/run/podman/podman.sock")
+}
+
+func findSocket(sockets []string) string {
+	for _, socket := range sockets {
+		if fileExists(socket) {
+			return socket
+		}
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func dockerApiVersion() string {
+	if version := os.Getenv("DOCKER_API_VERSION"); version != "" {
+		return version
+	}
+	return client.DefaultVersion
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPodmanSockets(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	expected := []string{"/run/user/1000/podman/podman.sock", "/run/podman/podman.sock"}
+	if sockets := podmanSockets(); !reflect.DeepEqual(expected, sockets) {
+		t.Errorf("Should be %v, but is %v", expected, sockets)
+	}
+}
+
+func TestFindSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvchk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "podman.sock")
+	if err := ioutil.WriteFile(socket, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := findSocket([]string{filepath.Join(dir, "missing.sock"), socket}); found != socket {
+		t.Errorf("Should be %s, but is %s", socket, found)
+	}
+	if found := findSocket([]string{filepath.Join(dir, "missing.sock")}); found != "" {
+		t.Errorf("Should not find socket, but is %s", found)
+	}
+}
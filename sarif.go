@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// sarifLevels map update levels to severity of results.
+var sarifLevels = map[string]string{
+	UpdatePatch.String(): "note",
+	UpdateMinor.String(): "warning",
+	UpdateMajor.String(): "error",
+}
+
+// SarifReporter prints a result per image with updates above the allowed level. Images referenced in files point
+// at the file and line, so that code scanning shows them inline.
+type SarifReporter struct {
+	allowed UpdateLevel
+}
+
+func (sr SarifReporter) Report(w io.Writer, report Report) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "dvchk",
+			InformationUri: "https://github.com/aklimko/dvchk",
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, level := range []UpdateLevel{UpdatePatch, UpdateMinor, UpdateMajor} {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			Id:               sarifRuleId(level.String()),
+			ShortDescription: sarifMessage{Text: fmt.Sprintf("Newer %s version of image is available", level)},
+		})
+	}
+
+	for _, image := range report.Images {
+		if !image.Exceeds(sr.allowed) {
+			continue
+		}
+
+		result := sarifResult{
+			RuleId: sarifRuleId(image.Level),
+			Level:  sarifLevels[image.Level],
+			Message: sarifMessage{Text: fmt.Sprintf("%s can be updated to %s",
+				image.Image, strings.Join(image.NewerVersions, ", "))},
+		}
+
+		for _, source := range image.Sources {
+			result.Locations = append(result.Locations, sarifLocation{PhysicalLocation: &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{Uri: filepath.ToSlash(source.Path)},
+				Region:           sarifRegion{StartLine: source.Line},
+			}})
+		}
+		if image.Container != "" {
+			result.Locations = append(result.Locations, sarifLocation{
+				LogicalLocations: []sarifLogicalLocation{{Name: image.ContainerKey(), Kind: "container"}},
+			})
+		}
+
+		run.Results = append(run.Results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+func sarifRuleId(level string) string {
+	return level + "-update"
+}
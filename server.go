@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/context"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const serverShutdownTimeout = 5 * time.Second
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{"join": strings.Join}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dvchk</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.4em 1em; text-align: left; }
+.major { color: #c0392b; } .minor { color: #d35400; } .patch { color: #2980b9; } .unchecked { color: #999; }
+</style>
+</head>
+<body>
+<h1>dvchk</h1>
+<form method="post" action="/api/v1/check"><button type="submit">Check now</button></form>
+{{ if . }}<p>Last check at {{ .CheckedAt.Format "2006-01-02 15:04:05 MST" }}</p>
+<table>
+<tr><th>Container</th><th>Image</th><th>Current tag</th><th>Updates</th></tr>
+{{ range .Containers }}<tr>
+<td>{{ .Container }}</td><td>{{ .Image }}</td><td>{{ .CurrentTag }}</td>
+<td>{{ if not .Checked }}<span class="unchecked">not checked</span>{{ else if .NewerVersions }}<span class="{{ .Level }}">{{ join .NewerVersions ", " }} ({{ .Level }})</span>{{ else }}up to date{{ end }}</td>
+</tr>
+{{ end }}</table>
+{{ else }}<p>First check is in progress</p>
+{{ end }}</body>
+</html>
+`))
+
+// Server exposes results of the watcher over HTTP and allows triggering checks.
+type Server struct {
+	watcher *Watcher
+	metrics *Metrics
+	server  *http.Server
+}
+
+func NewServer(address string, watcher *Watcher, metrics *Metrics) *Server {
+	s := &Server{watcher: watcher, metrics: metrics}
+	s.server = &http.Server{Addr: address, Handler: s.handler()}
+	return s
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleDashboard)
+	mux.HandleFunc("/api/v1/results", s.handleResults)
+	mux.HandleFunc("/api/v1/check", s.handleCheck)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.Handle("/metrics", s.metrics)
+	return mux
+}
+
+// Start listens synchronously, so that an address in use is reported right away, and serves in background.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving results at http://%s/\n", listener.Addr())
+
+	go func() {
+		err := s.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "HTTP server failed, %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to stop HTTP server, %v\n", err)
+	}
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTemplate.Execute(w, s.watcher.Results().Get())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to render dashboard, %v\n", err)
+	}
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	results := s.watcher.Results().Get()
+	if results == nil {
+		http.Error(w, "first check is in progress", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
+}
+
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.watcher.Trigger()
+
+	// Browsers submitting the dashboard form are sent back to the dashboard.
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte("ok\n"))
+}
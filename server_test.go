@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer() (*httptest.Server, *Watcher) {
+	watcher := &Watcher{trigger: make(chan struct{}, 1), results: &ResultStore{}}
+	return httptest.NewServer(NewServer("", watcher, NewMetrics(Config{})).handler()), watcher
+}
+
+var testCheckResult = CheckResult{
+	Containers: map[string]string{"api": "author/api:1.0.0", "db": "postgres:15.3", "web": "nginx:latest"},
+	ImagesNewerVersions: ImagesNewerVersions{
+		newTestNewerVersions("author/api:1.0.0", "api", "1.0.1", "2.0.0"),
+		newTestNewerVersions("postgres:15.3", "db"),
+	},
+}
+
+func TestServerServesResults(t *testing.T) {
+	server, watcher := newTestServer()
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/api/v1/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	discardBody(response)
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Should be %d before first check, but is %d", http.StatusServiceUnavailable, response.StatusCode)
+	}
+
+	checkedAt := time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC)
+	watcher.Results().Set(testCheckResult, checkedAt)
+
+	response, err = http.Get(server.URL + "/api/v1/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var results Results
+	err = json.NewDecoder(response.Body).Decode(&results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Results{CheckedAt: checkedAt, Containers: []ContainerResult{
+		{Container: "api", Image: "author/api:1.0.0", CurrentTag: "1.0.0", Checked: true, NewerVersions: []string{"1.0.1", "2.0.0"}, Level: "major"},
+		{Container: "db", Image: "postgres:15.3", CurrentTag: "15.3", Checked: true, NewerVersions: []string{}, Level: "none"},
+		{Container: "web", Image: "nginx:latest", CurrentTag: "latest", NewerVersions: []string{}},
+	}}
+	if !reflect.DeepEqual(expected, results) {
+		t.Errorf("Should be %v, but is %v", expected, results)
+	}
+}
+
+func TestServerTriggersCheck(t *testing.T) {
+	server, watcher := newTestServer()
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		response, err := http.Post(server.URL+"/api/v1/check", "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		discardBody(response)
+
+		if response.StatusCode != http.StatusAccepted {
+			t.Errorf("Should be %d, but is %d", http.StatusAccepted, response.StatusCode)
+		}
+	}
+
+	if len(watcher.trigger) != 1 {
+		t.Errorf("Should be 1 pending check, but is %d", len(watcher.trigger))
+	}
+}
+
+func TestServerRendersDashboard(t *testing.T) {
+	server, watcher := newTestServer()
+	defer server.Close()
+
+	watcher.Results().Set(testCheckResult, time.Now())
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+
+	for _, expected := range []string{"<td>author/api:1.0.0</td>", "1.0.1, 2.0.0 (major)", "up to date", "not checked"} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Should contain %s, but is %s", expected, body)
+		}
+	}
+
+	response, err = http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	discardBody(response)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Should be %d, but is %d", http.StatusOK, response.StatusCode)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"golang.org/x/net/context"
+	"os"
+	"strings"
+)
+
+// stackNamespaceLabel holds name of the stack deployed by docker stack deploy, which is the compose project of
+// its services.
+const stackNamespaceLabel = "com.docker.stack.namespace"
+
+func getServices(cli *client.Client) ([]swarm.Service, error) {
+	return cli.ServiceList(context.Background(), types.ServiceListOptions{})
+}
+
+// getNetworkNames maps IDs of networks to their names, services refer to networks by their IDs.
+func getNetworkNames(cli *client.Client) (map[string]string, error) {
+	networks, err := cli.NetworkList(context.Background(), types.NetworkListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks, %v", err)
+	}
+
+	names := make(map[string]string)
+	for _, resource := range networks {
+		names[resource.ID] = resource.Name
+	}
+	return names, nil
+}
+
+// serviceImage returns image of the service without the digest Docker pins it to, e.g. nginx:1.25.3 for
+// nginx:1.25.3@sha256:...
+func serviceImage(service swarm.Service) string {
+	image := service.Spec.TaskTemplate.ContainerSpec.Image
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i]
+	}
+	return image
+}
+
+// serviceAsContainer describes the service as a container, so that filters apply to services too. Stack of the
+// service is its compose project and networks given by IDs are named by networkNames.
+func serviceAsContainer(service swarm.Service, networkNames map[string]string) types.Container {
+	labels := make(map[string]string)
+	for key, value := range service.Spec.Labels {
+		labels[key] = value
+	}
+	if namespace, present := service.Spec.Labels[stackNamespaceLabel]; present {
+		labels[composeProjectLabel] = namespace
+	}
+
+	// Networks of the service spec are deprecated, but still set by older clients.
+	attachments := append([]swarm.NetworkAttachmentConfig{}, service.Spec.TaskTemplate.Networks...)
+	attachments = append(attachments, service.Spec.Networks...)
+
+	networks := make(map[string]*network.EndpointSettings)
+	for _, attachment := range attachments {
+		name, present := networkNames[attachment.Target]
+		if !present {
+			name = attachment.Target
+		}
+		networks[name] = &network.EndpointSettings{NetworkID: attachment.Target, Aliases: attachment.Aliases}
+	}
+
+	return types.Container{
+		ID:              service.ID,
+		Names:           []string{"/" + service.Spec.Name},
+		Image:           serviceImage(service),
+		Labels:          labels,
+		NetworkSettings: &types.SummaryNetworkSettings{Networks: networks},
+	}
+}
+
+// CheckServicesImageTags checks images of Swarm services, which are reported in place of containers.
+func (v *VersionChecker) CheckServicesImageTags(host string, services []swarm.Service) {
+	for _, service := range services {
+		imageName := serviceImage(service)
+		fmt.Fprintf(os.Stderr, "Checking %s [%s]\n", imageName, containerKey(host, service.Spec.Name))
+
+		v.checkImageTags(imageName, service.Spec.Name, host)
+	}
+
+	fmt.Fprintln(os.Stderr)
+}
+
+// applyService updates image of a service labelled with dvchk.autoupdate the way docker service update --image
+// does. Swarm replaces tasks and rolls back according to the update config of the service.
+func (u *Updater) applyService(inv ImageNewerVersions) {
+	serviceName := inv.image.Container
+	ctx := context.Background()
+
+	service, _, err := u.cli.ServiceInspectWithRaw(ctx, serviceName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to inspect service %s, %v\n", serviceName, err)
+		return
+	}
+
+	levelName, present := service.Spec.Labels[autoUpdateLabel]
+	if !present {
+		return
+	}
+
+	tag := selectLabelledUpdateTag(inv, "service", serviceName, levelName)
+	if tag == "" {
+		return
+	}
+
+	newImage := withTag(inv.image, tag)
+	fmt.Fprintf(os.Stderr, "Updating service %s from %s to %s\n", serviceName, inv.imageName, newImage)
+
+	registryAuth, err := u.credentials.RegistryAuth(inv.image.Registry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read credentials of %s, %v\n", inv.image.Registry, err)
+		return
+	}
+
+	// Pinning the tag to its digest makes all nodes run the same image, like docker service update does.
+	pinnedImage := newImage
+	digest, err := u.digests(inv.image, tag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to resolve digest of %s, nodes will pull the tag independently, %v\n", newImage, err)
+	} else {
+		pinnedImage = newImage + "@" + digest
+	}
+
+	spec := service.Spec
+	spec.TaskTemplate.ContainerSpec.Image = pinnedImage
+	// Workers pull the image with credentials sent along the update.
+	options := types.ServiceUpdateOptions{EncodedRegistryAuth: registryAuth}
+	response, err := u.cli.ServiceUpdate(ctx, service.ID, service.Version, spec, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to update service %s, %v\n", serviceName, err)
+		return
+	}
+	for _, warning := range response.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning when updating service %s, %s\n", serviceName, warning)
+	}
+
+	fmt.Fprintf(os.Stderr, "Updated service %s to %s\n", serviceName, newImage)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestService(name string, image string, labels map[string]string) swarm.Service {
+	service := swarm.Service{ID: name + "-id"}
+	service.Version.Index = 42
+	service.Spec.Name = name
+	service.Spec.Labels = labels
+	service.Spec.TaskTemplate.ContainerSpec.Image = image
+	return service
+}
+
+func TestServiceImageStripsDigest(t *testing.T) {
+	cases := map[string]string{
+		"nginx:1.25.3@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac": "nginx:1.25.3",
+		"registry.com/author/api:1.0.0": "registry.com/author/api:1.0.0",
+	}
+
+	for image, expected := range cases {
+		if actual := serviceImage(newTestService("api", image, nil)); actual != expected {
+			t.Errorf("Should be %s, but is %s", expected, actual)
+		}
+	}
+}
+
+func TestContainerFiltersMatchServices(t *testing.T) {
+	containerFilters, _ := ParseContainerFilters([]string{"label=team=payments"}, []string{"name=*_db"})
+
+	var names []string
+	for _, service := range []swarm.Service{
+		newTestService("shop_api", "author/api:1.0.0", map[string]string{"team": "payments"}),
+		newTestService("shop_db", "postgres:15.3", map[string]string{"team": "payments"}),
+		newTestService("cart_api", "author/cart:1.0.0", map[string]string{"team": "checkout"}),
+	} {
+		if containerFilters.Matches(serviceAsContainer(service, nil)) {
+			names = append(names, service.Spec.Name)
+		}
+	}
+
+	if expected := []string{"shop_api"}; !reflect.DeepEqual(expected, names) {
+		t.Errorf("Should be %v, but is %v", expected, names)
+	}
+}
+
+func TestContainerFiltersMatchServicesOfStacksAndNetworks(t *testing.T) {
+	containerFilters, _ := ParseContainerFilters([]string{"compose-project=shop", "network=shop_backend"}, nil)
+
+	api := newTestService("shop_api", "author/api:1.0.0", map[string]string{stackNamespaceLabel: "shop"})
+	api.Spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: "n1"}}
+	web := newTestService("shop_web", "nginx:1.25.3", map[string]string{stackNamespaceLabel: "shop"})
+	web.Spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: "n2"}}
+	cart := newTestService("cart_api", "author/cart:1.0.0", map[string]string{stackNamespaceLabel: "cart"})
+	cart.Spec.Networks = []swarm.NetworkAttachmentConfig{{Target: "n1"}}
+
+	networkNames := map[string]string{"n1": "shop_backend", "n2": "shop_frontend"}
+	var names []string
+	for _, service := range []swarm.Service{api, web, cart} {
+		if containerFilters.Matches(serviceAsContainer(service, networkNames)) {
+			names = append(names, service.Spec.Name)
+		}
+	}
+
+	if expected := []string{"shop_api"}; !reflect.DeepEqual(expected, names) {
+		t.Errorf("Should be %v, but is %v", expected, names)
+	}
+	if _, present := api.Spec.Labels[composeProjectLabel]; present {
+		t.Error("Should not change labels of the service")
+	}
+}
+
+func TestUpdaterUpdatesServiceImage(t *testing.T) {
+	service := newTestService("shop_api", "author/api:1.0.0@sha256:4c0fdaa8", map[string]string{autoUpdateLabel: "minor"})
+
+	var updated swarm.ServiceSpec
+	var updatedPath string
+	var registryAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			updatedPath = r.URL.Path + "?" + r.URL.RawQuery
+			registryAuth = r.Header.Get("X-Registry-Auth")
+			_ = json.NewDecoder(r.Body).Decode(&updated)
+			_ = json.NewEncoder(w).Encode(types.ServiceUpdateResponse{})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(service)
+	}))
+	defer server.Close()
+
+	cli, err := client.NewClient("tcp://"+strings.TrimPrefix(server.URL, "http://"), "1.25", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, cleanup := newTestDockerCredentials(t, `{"auths": {"https://index.docker.io/v1/": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("hubuser:hubpass"))+`"}}}`)
+	defer cleanup()
+
+	inv := newTestNewerVersions(serviceImage(service), "shop_api", "1.0.1", "1.1.0", "2.0.0")
+	updater := &Updater{cli: cli, swarm: true, credentials: credentials, digests: func(image Image, tag string) (string, error) {
+		return "sha256:1b2c3d4e", nil
+	}}
+	updater.Apply(ImagesNewerVersions{inv})
+
+	if expected := "/v1.25/services/shop_api-id/update?version=42"; updatedPath != expected {
+		t.Errorf("Should be %s, but is %s", expected, updatedPath)
+	}
+	if expected := "author/api:1.1.0@sha256:1b2c3d4e"; updated.TaskTemplate.ContainerSpec.Image != expected {
+		t.Errorf("Should be %s, but is %s", expected, updated.TaskTemplate.ContainerSpec.Image)
+	}
+	if authConfig := decodeRegistryAuth(t, registryAuth); authConfig.Username != "hubuser" || authConfig.Password != "hubpass" {
+		t.Errorf("Should send credentials of Docker Hub, but is %+v", authConfig)
+	}
+
+	// Nodes pull the tag on their own when its digest cannot be resolved.
+	updater.digests = func(image Image, tag string) (string, error) {
+		return "", fmt.Errorf("unexpected status code 404")
+	}
+	updater.Apply(ImagesNewerVersions{inv})
+
+	if expected := "author/api:1.1.0"; updated.TaskTemplate.ContainerSpec.Image != expected {
+		t.Errorf("Should be %s, but is %s", expected, updated.TaskTemplate.ContainerSpec.Image)
+	}
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
-	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	log "github.com/sirupsen/logrus"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -24,6 +26,35 @@ type Token struct {
 	IssuedAt    time.Time `json:"issued_at"`
 }
 
+// TokenStore keeps authorization headers of repositories, so that further requests for the same
+// repository, e.g. for manifests, do not need to authenticate again.
+type TokenStore struct {
+	mutex  sync.Mutex
+	tokens map[string]string
+}
+
+func NewTokenStore() *TokenStore {
+	return &TokenStore{tokens: make(map[string]string)}
+}
+
+func (ts *TokenStore) Get(image Image) string {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	return ts.tokens[tokenKey(image)]
+}
+
+func (ts *TokenStore) Set(image Image, authHeader string) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.tokens[tokenKey(image)] = authHeader
+}
+
+func tokenKey(image Image) string {
+	return image.Registry + "/" + image.Repository()
+}
+
 type ImageNameTags struct {
 	Name string   `json:"name"`
 	Tags []string `json:"tags"`
@@ -31,17 +62,48 @@ type ImageNameTags struct {
 
 type TagDownloader struct {
 	apiClient       *ApiClient
+	mirrors         []RegistryMirror
+	tokens          *TokenStore
 	validRegistries map[string]bool
 }
 
-func NewTagDownloader(apiClient *ApiClient) TagDownloader {
+func NewTagDownloader(apiClient *ApiClient, mirrors []RegistryMirror, tokens *TokenStore) TagDownloader {
+	for _, mirror := range mirrors {
+		if mirror.PlainHttp {
+			apiClient.UsePlainHttp(mirror.Host)
+		}
+	}
+
 	return TagDownloader{
 		apiClient:       apiClient,
+		mirrors:         mirrors,
+		tokens:          tokens,
 		validRegistries: make(map[string]bool),
 	}
 }
 
+// DownloadWithoutAuth downloads tags of the image. Docker Hub images are looked up in registry mirrors
+// first, falling back to Docker Hub when none of the mirrors has the image.
 func (td TagDownloader) DownloadWithoutAuth(image Image) (status DownloadStatus, tags []string, authUrl AuthUrl, err error) {
+	if image.Registry == defaultRegistry {
+		for _, mirror := range td.mirrors {
+			mirrorImage := image
+			mirrorImage.Registry = mirror.Host
+
+			status, tags, _, err := td.downloadWithoutAuth(mirrorImage)
+			if err == nil && status == StatusImgSuccessful {
+				log.Debugf("Downloaded tags for %s from mirror %s\n", image.LocalFullName, mirror.Host)
+				return status, tags, AuthUrl{}, nil
+			}
+
+			log.Debugf("Failed to download tags for %s from mirror %s\n", image.LocalFullName, mirror.Host)
+		}
+	}
+
+	return td.downloadWithoutAuth(image)
+}
+
+func (td TagDownloader) downloadWithoutAuth(image Image) (status DownloadStatus, tags []string, authUrl AuthUrl, err error) {
 	errorWrap := func(err error) (DownloadStatus, []string, AuthUrl, error) {
 		return -1, nil, AuthUrl{}, err
 	}
@@ -131,12 +193,10 @@ func (td TagDownloader) validateRegistry(image Image) error {
 }
 
 func prepareUrlError(error *url.Error) error {
-	switch err := error.Err.(type) {
-	case x509.UnknownAuthorityError, x509.CertificateInvalidError, x509.InsecureAlgorithmError:
-		return fmt.Errorf("certificate is invalid (consider running program with insecure TLS option), %v", err)
-	default:
-		return error
+	if isCertificateError(error) {
+		return fmt.Errorf("certificate is invalid (consider providing CA certificate or running program with insecure TLS option), %v", error.Err)
 	}
+	return error
 }
 
 func createAuthUrl(wwwAuthenticate string) (AuthUrl, error) {
@@ -178,7 +238,7 @@ func (td TagDownloader) DownloadWithAuth(image *ImageAuthUrl, credentials Creden
 			return nil, fmt.Errorf("Failed to unmarshal tags for %s, error:%v\n", imageName, err)
 		}
 
-		fmt.Printf("Successfully downloaded tags for %s\n", imageName)
+		fmt.Fprintf(os.Stderr, "Successfully downloaded tags for %s\n", imageName)
 		return tags, nil
 	} else {
 		return nil, fmt.Errorf("Failed authentication for %s\n", imageName)
@@ -191,11 +251,17 @@ func (td TagDownloader) getTagsResponseUsingTokenResponse(responseToken *http.Re
 		return nil, fmt.Errorf("Failed to unmarshal token for %s, error:%v\n", image.LocalFullName, err)
 	}
 
-	tagsListResponse, err := td.apiClient.GetTagListAuthenticated(image, prepareAuthHeader(token.Token))
+	authHeader := prepareAuthHeader(token.Token)
+
+	tagsListResponse, err := td.apiClient.GetTagListAuthenticated(image, authHeader)
 	if err != nil {
 		return nil, fmt.Errorf("Response failed for %s, error:%v\n", image.LocalFullName, err)
 	}
 
+	if tagsListResponse.StatusCode == http.StatusOK {
+		td.tokens.Set(image, authHeader)
+	}
+
 	return tagsListResponse, nil
 }
 
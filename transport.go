@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"golang.org/x/net/http/httpproxy"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const defaultCertsDir = "/etc/docker/certs.d"
+
+// RegistryTransport picks transport based on the registry host, so custom certificates and insecure
+// settings apply only to the registries they were configured for.
+type RegistryTransport struct {
+	proxy              func(*url.URL) (*url.URL, error)
+	baseTlsConfig      *tls.Config
+	tlsConfigs         map[string]*tls.Config
+	insecureRegistries *InsecureRegistries
+
+	mutex      sync.Mutex
+	transports map[string]*http.Transport
+}
+
+func NewRegistryTransport(config Config, insecureRegistries *InsecureRegistries) (*RegistryTransport, error) {
+	baseTlsConfig, err := createBaseTlsConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	registriesTls, err := readCertsDir(config.CertsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, registryConfig := range config.Registries {
+		registry := registryConfig.Name
+		registryTls := registriesTls[registry]
+		if registryConfig.CaCert != "" {
+			registryTls.caCerts = append(registryTls.caCerts, registryConfig.CaCert)
+		}
+		if registryConfig.ClientCert != "" || registryConfig.ClientKey != "" {
+			registryTls.clientPairs = append(registryTls.clientPairs, [2]string{registryConfig.ClientCert, registryConfig.ClientKey})
+		}
+		registriesTls[registry] = registryTls
+	}
+
+	rt := &RegistryTransport{
+		proxy:              createProxyFunc(config),
+		baseTlsConfig:      baseTlsConfig,
+		tlsConfigs:         make(map[string]*tls.Config),
+		insecureRegistries: insecureRegistries,
+		transports:         make(map[string]*http.Transport),
+	}
+
+	for registry, registryTls := range registriesTls {
+		tlsConfig, err := registryTls.apply(config, baseTlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS configuration for registry %s, %v", registry, err)
+		}
+
+		rt.tlsConfigs[registry] = tlsConfig
+	}
+
+	return rt, nil
+}
+
+func (rt *RegistryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return rt.transportFor(request.URL.Host).RoundTrip(request)
+}
+
+func (rt *RegistryTransport) transportFor(host string) *http.Transport {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	transport, present := rt.transports[host]
+	if present {
+		return transport
+	}
+
+	tlsConfig, present := rt.tlsConfigs[host]
+	if !present {
+		tlsConfig = rt.baseTlsConfig
+	}
+
+	if rt.insecureRegistries.Contains(host) {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	transport = &http.Transport{Proxy: rt.proxyForRequest, TLSClientConfig: tlsConfig}
+	rt.transports[host] = transport
+
+	return transport
+}
+
+func (rt *RegistryTransport) proxyForRequest(request *http.Request) (*url.URL, error) {
+	return rt.proxy(request.URL)
+}
+
+// createProxyFunc uses standard proxy environment variables, overridden by proxy options if present.
+func createProxyFunc(config Config) func(*url.URL) (*url.URL, error) {
+	proxyConfig := httpproxy.FromEnvironment()
+
+	if config.Proxy != "" {
+		proxyConfig.HTTPProxy = config.Proxy
+		proxyConfig.HTTPSProxy = config.Proxy
+	}
+	if config.NoProxy != "" {
+		proxyConfig.NoProxy = config.NoProxy
+	}
+
+	return proxyConfig.ProxyFunc()
+}
+
+func createBaseTlsConfig(config Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Insecure}
+
+	if config.CaCert == "" {
+		return tlsConfig, nil
+	}
+
+	pool, err := createCertPool([]string{config.CaCert})
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.RootCAs = pool
+
+	return tlsConfig, nil
+}
+
+type registryTls struct {
+	caCerts     []string
+	clientPairs [][2]string
+}
+
+func (rt registryTls) apply(config Config, base *tls.Config) (*tls.Config, error) {
+	tlsConfig := base.Clone()
+
+	if len(rt.caCerts) > 0 {
+		caCerts := rt.caCerts
+		if config.CaCert != "" {
+			caCerts = append([]string{config.CaCert}, caCerts...)
+		}
+
+		pool, err := createCertPool(caCerts)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	for _, pair := range rt.clientPairs {
+		certificate, err := tls.LoadX509KeyPair(pair[0], pair[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s, %v", pair[0], err)
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, certificate)
+	}
+
+	return tlsConfig, nil
+}
+
+// readCertsDir reads certificates in the layout used by the Docker daemon, where each registry has
+// its own directory with *.crt CA certificates and *.cert/*.key client certificate pairs.
+func readCertsDir(certsDir string) (map[string]registryTls, error) {
+	registriesTls := make(map[string]registryTls)
+
+	if certsDir == "" {
+		return registriesTls, nil
+	}
+
+	registryDirs, err := ioutil.ReadDir(certsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return registriesTls, nil
+		}
+		return nil, err
+	}
+
+	for _, registryDir := range registryDirs {
+		if !registryDir.IsDir() {
+			continue
+		}
+
+		registry := registryDir.Name()
+		registryTls, err := readRegistryCertsDir(filepath.Join(certsDir, registry))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificates of registry %s, %v", registry, err)
+		}
+
+		registriesTls[registry] = registryTls
+	}
+
+	return registriesTls, nil
+}
+
+func readRegistryCertsDir(dir string) (registryTls, error) {
+	var result registryTls
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return result, err
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(dir, name)
+
+		switch filepath.Ext(name) {
+		case ".crt":
+			result.caCerts = append(result.caCerts, path)
+		case ".cert":
+			keyPath := strings.TrimSuffix(path, ".cert") + ".key"
+			if _, err := os.Stat(keyPath); err != nil {
+				return result, fmt.Errorf("missing key for client certificate %s", path)
+			}
+			result.clientPairs = append(result.clientPairs, [2]string{path, keyPath})
+		case ".key":
+			certPath := strings.TrimSuffix(path, ".key") + ".cert"
+			if _, err := os.Stat(certPath); err != nil {
+				return result, fmt.Errorf("missing client certificate for key %s", path)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func createCertPool(caCerts []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	for _, caCert := range caCerts {
+		pem, err := ioutil.ReadFile(caCert)
+		if err != nil {
+			return nil, err
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in %s", caCert)
+		}
+	}
+
+	return pool, nil
+}
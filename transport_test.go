@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApiClientTrustsCertsDirCa(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	certsDir, err := ioutil.TempDir("", "certs.d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(certsDir)
+
+	registry := strings.TrimPrefix(server.URL, "https://")
+
+	apiClient, err := NewApiClient(Config{CertsDir: certsDir, Timeout: 5}, NewRateLimits(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = apiClient.GetV2(registry)
+	if err == nil || !isCertificateError(err) {
+		t.Fatalf("Should fail with certificate error, but error is %v", err)
+	}
+
+	registryDir := filepath.Join(certsDir, registry)
+	if err := os.Mkdir(registryDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(filepath.Join(registryDir, "ca.crt"), caCert, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	apiClient, err = NewApiClient(Config{CertsDir: certsDir, Timeout: 5}, NewRateLimits(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := apiClient.GetV2(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Should be %d, but is %d", http.StatusOK, response.StatusCode)
+	}
+}
+
+func TestRegistryTransportUsesProxy(t *testing.T) {
+	insecureRegistries, _ := NewInsecureRegistries(nil)
+
+	transport, err := NewRegistryTransport(Config{Proxy: "http://proxy.local:3128", NoProxy: "internal.local"}, insecureRegistries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"https://registry-1.docker.io/v2/":    "http://proxy.local:3128",
+		"https://registry.internal.local/v2/": "",
+	}
+
+	for requestUrl, expected := range cases {
+		request, _ := http.NewRequest("GET", requestUrl, nil)
+
+		proxyUrl, err := transport.proxyForRequest(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var proxy string
+		if proxyUrl != nil {
+			proxy = proxyUrl.String()
+		}
+
+		if proxy != expected {
+			t.Errorf("Proxy for %s should be %q, but is %q", requestUrl, expected, proxy)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/hashicorp/go-version"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	autoUpdateLabel = "dvchk.autoupdate"
+
+	oldContainerSuffix   = "-dvchk-old"
+	containerStopTimeout = 10 * time.Second
+	healthPollInterval   = time.Second
+)
+
+// Updater pulls newer versions of images and recreates containers labelled with dvchk.autoupdate, which tells
+// the highest update level that can be applied automatically. Containers that do not become healthy are
+// rolled back to the previous image.
+type Updater struct {
+	cli *client.Client
+	// host is the name of the Docker host, only its containers are updated.
+	host string
+	// swarm tells to update Swarm services instead of containers.
+	swarm       bool
+	credentials DockerCredentials
+	// digests resolves digest of the tag in the registry, services are pinned to it.
+	digests      func(image Image, tag string) (string, error)
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+func NewUpdater(host DockerHost, config Config, digests func(image Image, tag string) (string, error)) *Updater {
+	return &Updater{
+		cli:          host.cli,
+		host:         host.Name,
+		swarm:        config.Swarm,
+		credentials:  NewDockerCredentials(),
+		digests:      digests,
+		timeout:      config.ApplyTimeout,
+		pollInterval: healthPollInterval,
+	}
+}
+
+func (u *Updater) Apply(imagesNewerVersions ImagesNewerVersions) {
+	for _, inv := range imagesNewerVersions {
+		containerName := inv.image.Container
+		if containerName == "" || inv.image.Host != u.host || len(inv.newerVersions) == 0 {
+			continue
+		}
+		if u.swarm {
+			u.applyService(inv)
+			continue
+		}
+
+		old, err := u.cli.ContainerInspect(context.Background(), containerName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to inspect container %s, %v\n", containerName, err)
+			continue
+		}
+
+		levelName, present := old.Config.Labels[autoUpdateLabel]
+		if !present {
+			continue
+		}
+		// Stopped containers are checked with --all-containers, but updating them would start them.
+		if old.State == nil || !old.State.Running {
+			log.Debugf("Not updating container %s which is not running", containerName)
+			continue
+		}
+
+		tag := selectLabelledUpdateTag(inv, "container", containerName, levelName)
+		if tag == "" {
+			continue
+		}
+
+		newImage := withTag(inv.image, tag)
+		fmt.Fprintf(os.Stderr, "Updating container %s from %s to %s\n", containerName, inv.imageName, newImage)
+
+		err = u.update(old, inv.image.Registry, newImage)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to update container %s, %v\n", containerName, err)
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Updated container %s to %s\n", containerName, newImage)
+	}
+}
+
+// selectLabelledUpdateTag chooses tag to update to within the level from dvchk.autoupdate label of given container
+// or service, it is empty when there is none.
+func selectLabelledUpdateTag(inv ImageNewerVersions, kind string, name string, levelName string) string {
+	level, err := ParseUpdateLevel(levelName)
+	if err != nil || level == UpdateNone {
+		fmt.Fprintf(os.Stderr, "Ignoring %s %s due to invalid %s label %s\n", kind, name, autoUpdateLabel, levelName)
+		return ""
+	}
+
+	tag := selectUpdateTag(inv, level)
+	if tag == "" {
+		log.Debugf("No newer version of %s within %s updates\n", inv.imageName, level)
+	}
+	return tag
+}
+
+// selectUpdateTag chooses the highest newer version within the update level which is available for the platform.
+func selectUpdateTag(inv ImageNewerVersions, level UpdateLevel) string {
+	var selected *version.Version
+	var selectedTag string
+
+	for _, newerVersion := range inv.newerVersions {
+		if inv.unavailable[newerVersion] || GetUpdateLevel(inv.image.Tag, newerVersion) > level {
+			continue
+		}
+
+		v, err := version.NewSemver(newerVersion)
+		if err != nil {
+			continue
+		}
+
+		if selected == nil || v.GreaterThan(selected) {
+			selected = v
+			selectedTag = newerVersion
+		}
+	}
+
+	return selectedTag
+}
+
+func withTag(image Image, tag string) string {
+	return strings.TrimSuffix(image.LocalFullName, ":"+image.Tag) + ":" + tag
+}
+
+func (u *Updater) update(old types.ContainerJSON, registry string, newImage string) error {
+	ctx := context.Background()
+
+	err := u.pull(registry, newImage)
+	if err != nil {
+		return fmt.Errorf("failed to pull %s, %v", newImage, err)
+	}
+
+	var imageConfig *container.Config
+	oldImage, _, err := u.cli.ImageInspectWithRaw(ctx, old.Image)
+	if err == nil {
+		imageConfig = oldImage.Config
+	} else {
+		log.Debugf("Failed to inspect image of container %s, %v\n", old.Name, err)
+	}
+
+	config, hostConfig, networkingConfig, otherNetworks := recreateConfig(old, imageConfig, newImage)
+	name := strings.TrimPrefix(old.Name, "/")
+
+	stopTimeout := containerStopTimeout
+	err = u.cli.ContainerStop(ctx, old.ID, &stopTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to stop container, %v", err)
+	}
+
+	err = u.cli.ContainerRename(ctx, old.ID, name+oldContainerSuffix)
+	if err != nil {
+		u.restore(old, name)
+		return fmt.Errorf("failed to rename container, %v", err)
+	}
+
+	created, err := u.cli.ContainerCreate(ctx, config, hostConfig, networkingConfig, name)
+	if err != nil {
+		u.restore(old, name)
+		return fmt.Errorf("failed to create container, %v", err)
+	}
+
+	err = u.start(created.ID, otherNetworks)
+	if err != nil {
+		u.rollback(created.ID, old, name)
+		return fmt.Errorf("%v, rolled back to %s", err, old.Config.Image)
+	}
+
+	err = u.cli.ContainerRemove(ctx, old.ID, types.ContainerRemoveOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to remove previous container %s%s, %v\n", name, oldContainerSuffix, err)
+	}
+
+	return nil
+}
+
+// pull pulls the image with credentials of its registry from Docker config, as the daemon does not read them.
+func (u *Updater) pull(registry string, image string) error {
+	registryAuth, err := u.credentials.RegistryAuth(registry)
+	if err != nil {
+		return fmt.Errorf("failed to read credentials of %s, %v", registry, err)
+	}
+
+	// The client pulls only canonical references, e.g. docker.io/library/nginx:1.25.3 instead of nginx:1.25.3.
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return err
+	}
+
+	body, err := u.cli.ImagePull(context.Background(), named.String(), types.ImagePullOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// Pull errors are reported in the progress stream rather than by status code.
+	decoder := json.NewDecoder(body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+
+		err := decoder.Decode(&message)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+	}
+}
+
+func (u *Updater) start(id string, otherNetworks map[string]*network.EndpointSettings) error {
+	ctx := context.Background()
+
+	for networkName, endpoint := range otherNetworks {
+		err := u.cli.NetworkConnect(ctx, networkName, id, endpoint)
+		if err != nil {
+			return fmt.Errorf("failed to connect network %s, %v", networkName, err)
+		}
+	}
+
+	err := u.cli.ContainerStart(ctx, id, types.ContainerStartOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start container, %v", err)
+	}
+
+	return u.waitHealthy(id)
+}
+
+// waitHealthy waits until the container reports healthy status. Containers without health check have to keep
+// running until the timeout.
+func (u *Updater) waitHealthy(id string) error {
+	deadline := time.Now().Add(u.timeout)
+
+	for {
+		info, err := u.cli.ContainerInspect(context.Background(), id)
+		if err != nil {
+			return err
+		}
+
+		state := info.State
+		if !state.Running || state.Restarting {
+			return fmt.Errorf("container exited with code %d", state.ExitCode)
+		}
+
+		if state.Health != nil {
+			switch state.Health.Status {
+			case types.Healthy:
+				return nil
+			case types.Unhealthy:
+				return fmt.Errorf("container is unhealthy")
+			}
+		}
+
+		if time.Now().After(deadline) {
+			if state.Health != nil {
+				return fmt.Errorf("container did not become healthy within %v", u.timeout)
+			}
+			return nil
+		}
+
+		time.Sleep(u.pollInterval)
+	}
+}
+
+func (u *Updater) rollback(id string, old types.ContainerJSON, name string) {
+	err := u.cli.ContainerRemove(context.Background(), id, types.ContainerRemoveOptions{Force: true})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to remove updated container %s, %v\n", name, err)
+	}
+
+	u.restore(old, name)
+}
+
+func (u *Updater) restore(old types.ContainerJSON, name string) {
+	ctx := context.Background()
+
+	err := u.cli.ContainerRename(ctx, old.ID, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to rename previous container back to %s, %v\n", name, err)
+	}
+
+	err = u.cli.ContainerStart(ctx, old.ID, types.ContainerStartOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start previous container %s, %v\n", name, err)
+	}
+}
+
+// recreateConfig creates configuration of the new container from the old one. Values that come from the old image,
+// like its environment, are dropped so that the new image can change them. Only one network can be given when
+// creating a container, others are returned to be connected before start.
+func recreateConfig(old types.ContainerJSON, imageConfig *container.Config, newImage string) (*container.Config, *container.HostConfig, *network.NetworkingConfig, map[string]*network.EndpointSettings) {
+	config := *old.Config
+	config.Image = newImage
+
+	// Docker sets hostname to the short ID of the container unless it was given.
+	if strings.HasPrefix(old.ID, config.Hostname) {
+		config.Hostname = ""
+	}
+
+	if imageConfig != nil {
+		config.Env = withoutValues(config.Env, imageConfig.Env)
+		config.Labels = withoutLabels(config.Labels, imageConfig.Labels)
+		if equalStrings(config.Cmd, imageConfig.Cmd) {
+			config.Cmd = nil
+		}
+		if equalStrings(config.Entrypoint, imageConfig.Entrypoint) {
+			config.Entrypoint = nil
+		}
+		if config.WorkingDir == imageConfig.WorkingDir {
+			config.WorkingDir = ""
+		}
+		if config.User == imageConfig.User {
+			config.User = ""
+		}
+	}
+
+	hostConfig := *old.HostConfig
+	hostConfig.Binds = append(hostConfig.Binds, namedVolumeBinds(old)...)
+
+	networks := make(map[string]*network.EndpointSettings)
+	if old.NetworkSettings != nil {
+		for networkName, endpoint := range old.NetworkSettings.Networks {
+			networks[networkName] = &network.EndpointSettings{
+				IPAMConfig: endpoint.IPAMConfig,
+				Links:      endpoint.Links,
+				Aliases:    withoutValues(endpoint.Aliases, []string{shortId(old.ID)}),
+			}
+		}
+	}
+
+	// Network mode names the primary network, default one is bridge.
+	primary := string(hostConfig.NetworkMode)
+	if hostConfig.NetworkMode.IsDefault() {
+		primary = "bridge"
+	}
+	if _, present := networks[primary]; !present {
+		names := make([]string, 0, len(networks))
+		for networkName := range networks {
+			names = append(names, networkName)
+		}
+		sort.Strings(names)
+		if len(names) > 0 {
+			primary = names[0]
+		}
+	}
+
+	networkingConfig := &network.NetworkingConfig{EndpointsConfig: make(map[string]*network.EndpointSettings)}
+	otherNetworks := make(map[string]*network.EndpointSettings)
+	for networkName, settings := range networks {
+		if networkName == primary {
+			networkingConfig.EndpointsConfig[networkName] = settings
+		} else {
+			otherNetworks[networkName] = settings
+		}
+	}
+
+	return &config, &hostConfig, networkingConfig, otherNetworks
+}
+
+// namedVolumeBinds keeps volumes mounted without binds, e.g. anonymous volumes declared by the image, which would
+// be created anew otherwise.
+func namedVolumeBinds(old types.ContainerJSON) []string {
+	configured := make(map[string]bool)
+	for _, bind := range old.HostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) > 1 {
+			configured[parts[1]] = true
+		}
+	}
+	for _, hostMount := range old.HostConfig.Mounts {
+		configured[hostMount.Target] = true
+	}
+
+	var binds []string
+	for _, mountPoint := range old.Mounts {
+		if mountPoint.Type != "volume" || mountPoint.Name == "" || configured[mountPoint.Destination] {
+			continue
+		}
+
+		bind := mountPoint.Name + ":" + mountPoint.Destination
+		if !mountPoint.RW {
+			bind += ":ro"
+		}
+		binds = append(binds, bind)
+	}
+
+	return binds
+}
+
+func shortId(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func withoutValues(values []string, excluded []string) []string {
+	excludedSet := make(map[string]bool)
+	for _, value := range excluded {
+		excludedSet[value] = true
+	}
+
+	var result []string
+	for _, value := range values {
+		if !excludedSet[value] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func withoutLabels(labels map[string]string, excluded map[string]string) map[string]string {
+	result := make(map[string]string)
+	for name, value := range labels {
+		if excludedValue, present := excluded[name]; !present || excludedValue != value {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
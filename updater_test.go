@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelectUpdateTag(t *testing.T) {
+	inv := newTestNewerVersions("postgres:15.3.1", "db", "15.3.2", "15.3.10", "15.4.0", "16.0.0")
+	inv.unavailable = map[string]bool{"15.4.0": true}
+
+	cases := map[UpdateLevel]string{
+		UpdatePatch: "15.3.10",
+		UpdateMinor: "15.3.10",
+		UpdateMajor: "16.0.0",
+	}
+
+	for level, expected := range cases {
+		if tag := selectUpdateTag(inv, level); tag != expected {
+			t.Errorf("%v should be %s, but is %s", level, expected, tag)
+		}
+	}
+}
+
+func TestWithTag(t *testing.T) {
+	image, _ := getImageDetails("localhost:5000/api:1.0.0")
+
+	if name := withTag(image, "1.1.0"); name != "localhost:5000/api:1.1.0" {
+		t.Errorf("Should be localhost:5000/api:1.1.0, but is %s", name)
+	}
+}
+
+func TestRecreateConfig(t *testing.T) {
+	old := types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:   "0123456789abcdef",
+			Name: "/api",
+			HostConfig: &container.HostConfig{
+				Binds:         []string{"/srv/api:/data"},
+				NetworkMode:   "backend",
+				RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
+			},
+		},
+		Mounts: []types.MountPoint{
+			{Type: "bind", Source: "/srv/api", Destination: "/data", RW: true},
+			{Type: "volume", Name: "cache", Destination: "/cache", RW: true},
+		},
+		Config: &container.Config{
+			Hostname: "0123456789ab",
+			Image:    "author/api:1.0.0",
+			Env:      []string{"PATH=/usr/bin", "API_VERSION=1.0.0", "DB_HOST=db"},
+			Labels:   map[string]string{"maintainer": "author", autoUpdateLabel: "minor"},
+			Cmd:      []string{"serve"},
+		},
+		NetworkSettings: &types.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+			"backend":  {Aliases: []string{"api", "0123456789ab"}, IPAddress: "172.18.0.2"},
+			"frontend": {Aliases: []string{"api"}},
+		}},
+	}
+	imageConfig := &container.Config{
+		Env:    []string{"PATH=/usr/bin", "API_VERSION=1.0.0"},
+		Labels: map[string]string{"maintainer": "author"},
+		Cmd:    []string{"serve"},
+	}
+
+	config, hostConfig, networkingConfig, otherNetworks := recreateConfig(old, imageConfig, "author/api:1.1.0")
+
+	expectedConfig := &container.Config{
+		Image:  "author/api:1.1.0",
+		Env:    []string{"DB_HOST=db"},
+		Labels: map[string]string{autoUpdateLabel: "minor"},
+	}
+	if !reflect.DeepEqual(expectedConfig, config) {
+		t.Errorf("Should be %+v, but is %+v", expectedConfig, config)
+	}
+
+	expectedBinds := []string{"/srv/api:/data", "cache:/cache"}
+	if !reflect.DeepEqual(expectedBinds, hostConfig.Binds) || hostConfig.RestartPolicy.Name != "unless-stopped" {
+		t.Errorf("Should be %v with restart policy, but is %v %v", expectedBinds, hostConfig.Binds, hostConfig.RestartPolicy)
+	}
+
+	expectedNetworking := map[string]*network.EndpointSettings{"backend": {Aliases: []string{"api"}}}
+	if !reflect.DeepEqual(expectedNetworking, networkingConfig.EndpointsConfig) {
+		t.Errorf("Should be %v, but is %v", expectedNetworking, networkingConfig.EndpointsConfig)
+	}
+
+	expectedOther := map[string]*network.EndpointSettings{"frontend": {Aliases: []string{"api"}}}
+	if !reflect.DeepEqual(expectedOther, otherNetworks) {
+		t.Errorf("Should be %v, but is %v", expectedOther, otherNetworks)
+	}
+}
+
+func TestUpdaterWaitsForHealthyContainer(t *testing.T) {
+	cases := map[string]struct {
+		states  []types.ContainerState
+		healthy bool
+	}{
+		"healthy": {[]types.ContainerState{
+			{Running: true, Health: &types.Health{Status: types.Starting}},
+			{Running: true, Health: &types.Health{Status: types.Healthy}},
+		}, true},
+		"unhealthy": {[]types.ContainerState{
+			{Running: true, Health: &types.Health{Status: types.Starting}},
+			{Running: true, Health: &types.Health{Status: types.Unhealthy}},
+		}, false},
+		"exited":                {[]types.ContainerState{{Running: false, ExitCode: 1}}, false},
+		"running until timeout": {[]types.ContainerState{{Running: true}}, true},
+	}
+
+	for name, c := range cases {
+		inspections := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state := c.states[len(c.states)-1]
+			if inspections < len(c.states) {
+				state = c.states[inspections]
+			}
+			inspections++
+
+			_ = json.NewEncoder(w).Encode(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: "api", State: &state}})
+		}))
+
+		cli, err := client.NewClient("tcp://"+strings.TrimPrefix(server.URL, "http://"), "1.25", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updater := &Updater{cli: cli, timeout: 20 * time.Millisecond, pollInterval: time.Millisecond}
+		err = updater.waitHealthy("api")
+		if (err == nil) != c.healthy {
+			t.Errorf("%s should be healthy %v, but is %v", name, c.healthy, err)
+		}
+
+		server.Close()
+	}
+}
+
+func TestUpdaterPullsWithRegistryCredentials(t *testing.T) {
+	credentials, cleanup := newTestDockerCredentials(t, `{"auths": {"registry.com": {"username": "user", "password": "secret"}}}`)
+	defer cleanup()
+
+	var registryAuth, pulled string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryAuth = r.Header.Get("X-Registry-Auth")
+		pulled = r.URL.Query().Get("fromImage") + ":" + r.URL.Query().Get("tag")
+
+		if r.URL.Query().Get("tag") == "missing" {
+			_, _ = w.Write([]byte(`{"status":"Pulling from author/api"}{"error":"manifest for registry.com/author/api:missing not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	cli, err := client.NewClient("tcp://"+strings.TrimPrefix(server.URL, "http://"), "1.25", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updater := &Updater{cli: cli, credentials: credentials}
+	err = updater.pull("registry.com", "registry.com/author/api:1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := types.AuthConfig{Username: "user", Password: "secret", ServerAddress: "registry.com"}
+	if authConfig := decodeRegistryAuth(t, registryAuth); pulled != "registry.com/author/api:1.1.0" || !reflect.DeepEqual(expected, authConfig) {
+		t.Errorf("Should pull registry.com/author/api:1.1.0 with %v, but pulled %s with %v", expected, pulled, authConfig)
+	}
+
+	err = updater.pull("registry.com", "registry.com/author/api:missing")
+	if err == nil || err.Error() != "manifest for registry.com/author/api:missing not found" {
+		t.Errorf("Should fail with pull error, but is %v", err)
+	}
+}
+
+func TestUpdaterRollsBackFailedUpdate(t *testing.T) {
+	credentials, cleanup := newTestDockerCredentials(t, `{}`)
+	defer cleanup()
+
+	old := types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{ID: "old", Name: "/api", Image: "sha256:abc", HostConfig: &container.HostConfig{NetworkMode: "bridge"}},
+		Config:            &container.Config{Image: "author/api:1.0.0"},
+		NetworkSettings: &types.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+			"bridge":  {},
+			"backend": {Aliases: []string{"api"}},
+		}},
+	}
+
+	updated := []string{
+		"POST /images/create docker.io/author/api:1.1.0",
+		"GET /images/sha256:abc/json",
+		"POST /containers/old/stop",
+		"POST /containers/old/rename api-dvchk-old",
+		"POST /containers/create api",
+		"POST /networks/backend/connect",
+		"POST /containers/new/start",
+		"GET /containers/new/json",
+	}
+	restored := []string{
+		"POST /containers/old/rename api",
+		"POST /containers/old/start",
+	}
+	rolledBack := append([]string{"DELETE /containers/new"}, restored...)
+
+	cases := map[string]struct {
+		failing  string
+		health   string
+		requests []string
+	}{
+		"updated":           {"", types.Healthy, append(updated, "DELETE /containers/old")},
+		"create fails":      {"POST /containers/create api", "", append(updated[:5:5], restored...)},
+		"network fails":     {"POST /networks/backend/connect", "", append(updated[:6:6], rolledBack...)},
+		"start fails":       {"POST /containers/new/start", "", append(updated[:7:7], rolledBack...)},
+		"becomes unhealthy": {"", types.Unhealthy, append(updated, rolledBack...)},
+	}
+
+	for name, c := range cases {
+		var requests []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			request := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/v1.25")
+			switch {
+			case strings.HasPrefix(request, "POST /images/create"):
+				request += " " + r.URL.Query().Get("fromImage") + ":" + r.URL.Query().Get("tag")
+			case strings.HasSuffix(request, "/rename"), strings.HasSuffix(request, "/create"):
+				request += " " + r.URL.Query().Get("name")
+			}
+			requests = append(requests, request)
+
+			if request == c.failing {
+				http.Error(w, "failed", http.StatusInternalServerError)
+				return
+			}
+			switch request {
+			case "GET /images/sha256:abc/json":
+				_ = json.NewEncoder(w).Encode(types.ImageInspect{ID: "sha256:abc", Config: &container.Config{}})
+			case "POST /containers/create api":
+				_ = json.NewEncoder(w).Encode(container.ContainerCreateCreatedBody{ID: "new"})
+			case "GET /containers/new/json":
+				state := &types.ContainerState{Running: true, Health: &types.Health{Status: c.health}}
+				_ = json.NewEncoder(w).Encode(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: "new", State: state}})
+			}
+		}))
+
+		cli, err := client.NewClient("tcp://"+strings.TrimPrefix(server.URL, "http://"), "1.25", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updater := &Updater{cli: cli, credentials: credentials, timeout: time.Second, pollInterval: time.Millisecond}
+		err = updater.update(old, "docker.io", "author/api:1.1.0")
+		if (err == nil) != (name == "updated") {
+			t.Errorf("%s should succeed %v, but is %v", name, name == "updated", err)
+		}
+		if !reflect.DeepEqual(c.requests, requests) {
+			t.Errorf("%s should be %v, but is %v", name, c.requests, requests)
+		}
+
+		server.Close()
+	}
+}
@@ -3,12 +3,12 @@ package main
 import (
 	"fmt"
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/net/context"
+	"io"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 )
 
 const defaultRegistry = "registry-1.docker.io"
@@ -35,20 +35,91 @@ type Image struct {
 	Author   string
 	Name     string
 	Tag      string
+
+	// Container is the name of the container or of the Swarm service running the image, if any.
+	Container string
+	// Host is the name of the Docker host running the container, when multiple hosts are checked.
+	Host string
+}
+
+// ContainerKey identifies the container running the image among containers of all checked hosts.
+func (i Image) ContainerKey() string {
+	return containerKey(i.Host, i.Container)
+}
+
+func containerKey(host string, container string) string {
+	if host == "" || container == "" {
+		return container
+	}
+	return host + "/" + container
+}
+
+// Repository returns path of the image in its registry.
+func (i Image) Repository() string {
+	if i.Author == "" {
+		return i.Name
+	}
+	return i.Author + "/" + i.Name
+}
+
+const (
+	FailureInvalidImage   = "invalid_image"
+	FailureUnsupportedTag = "unsupported_tag"
+	FailureDownload       = "download"
+	FailureUnauthorized   = "unauthorized"
+	FailureComparison     = "comparison"
+)
+
+// Final states of checked images.
+const (
+	StateUpToDate     = "up_to_date"
+	StateOutdated     = "outdated"
+	StateSkipped      = "skipped"
+	StateUnauthorized = "unauthorized"
+	StateError        = "error"
+)
+
+// ImageFailure describes why an image could not be checked.
+type ImageFailure struct {
+	Image  Image
+	Reason string
+	Err    error
+}
+
+// State tells whether the image was skipped as not checkable, or its check failed.
+func (f *ImageFailure) State() string {
+	switch f.Reason {
+	case FailureInvalidImage, FailureUnsupportedTag:
+		return StateSkipped
+	case FailureUnauthorized:
+		return StateUnauthorized
+	default:
+		return StateError
+	}
 }
 
 type ImageStorage struct {
 	Successful   []*ImageTags
 	Unauthorized []*ImageAuthUrl
+	Failed       []*ImageFailure
 }
 
 type VersionChecker struct {
 	tagDownloader TagDownloader
 	storage       *ImageStorage
+	// downloads holds tags downloaded by repository, so that images used on multiple hosts are looked up once.
+	downloads map[string]*tagDownload
+}
+
+type tagDownload struct {
+	status  DownloadStatus
+	tags    []string
+	authUrl AuthUrl
+	err     error
 }
 
 func NewVersionChecker(tagDownloader TagDownloader, storage *ImageStorage) VersionChecker {
-	return VersionChecker{tagDownloader: tagDownloader, storage: storage}
+	return VersionChecker{tagDownloader: tagDownloader, storage: storage, downloads: make(map[string]*tagDownload)}
 }
 
 func main() {
@@ -56,88 +127,278 @@ func main() {
 
 	setupLogging(config)
 
-	apiClient := NewApiClient(config)
-	tagDownloader := NewTagDownloader(apiClient)
+	if config.PlatformCheck != "" && config.PlatformCheck != PlatformCheckFlag && config.PlatformCheck != PlatformCheckHide {
+		fmt.Fprintf(os.Stderr, "%s is invalid platform check mode\n", config.PlatformCheck)
+		os.Exit(1)
+	}
 
-	storage := &ImageStorage{}
-	versionChecker := NewVersionChecker(tagDownloader, storage)
-	authorizer := NewAuthorizer(tagDownloader, storage)
+	// Serve mode checks on schedule like watch mode.
+	if config.Serve {
+		config.Watch = true
+	}
 
-	containers := getRunningContainers()
-	versionChecker.CheckContainersImageTags(containers)
+	updateLevel, err := ParseUpdateLevel(config.UpdateLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if (config.Write || config.Diff) && len(config.Files) == 0 {
+		fmt.Fprintln(os.Stderr, "Updating tags requires files to check")
+		os.Exit(1)
+	}
+	if config.Watch && len(config.Files) > 0 {
+		fmt.Fprintln(os.Stderr, "Files cannot be checked in watch mode")
+		os.Exit(1)
+	}
+	if config.Watch && (config.Output != OutputText || config.Format != "" || config.OutputFile != "") {
+		fmt.Fprintln(os.Stderr, "Watch mode prints results and changes as text, other outputs and output files cannot be used")
+		os.Exit(1)
+	}
+	if (config.AllContainers || config.Images) && len(config.Files) > 0 {
+		fmt.Fprintln(os.Stderr, "Containers and local images cannot be checked with files")
+		os.Exit(1)
+	}
+	if config.Swarm && (config.AllContainers || config.Images || config.Events || len(config.Files) > 0) {
+		fmt.Fprintln(os.Stderr, "Swarm services cannot be checked with containers, local images, events or files")
+		os.Exit(1)
+	}
+	if config.Kubernetes && (config.Swarm || config.AllContainers || config.Images || config.Events || config.Apply || len(config.Files) > 0) {
+		fmt.Fprintln(os.Stderr, "Kubernetes pods cannot be checked with Swarm services, containers, local images, events, updates or files")
+		os.Exit(1)
+	}
 
-	authorizer.Authorize()
+	allowedLevel, err := ParseUpdateLevel(config.AllowedLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	imagesNewerVersions := CheckImagesForNewerVersions(storage, config)
-	imagesNewerVersions.Print()
-}
+	reporter, err := NewReporter(config.Output, config.Format, allowedLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-func setupLogging(config Config) {
-	if config.Verbose {
-		log.SetLevel(log.DebugLevel)
+	hosts, err := NewDockerHosts(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if config.DaemonInsecureRegistries {
+		for _, host := range hosts {
+			if host.cli == nil {
+				continue
+			}
+			insecureRegistries, err := readDaemonInsecureRegistries(host.cli)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read insecure registries from Docker daemon, %v\n", err)
+			}
+			config.InsecureRegistries = append(config.InsecureRegistries, insecureRegistries...)
+		}
 	}
-}
 
-func getRunningContainers() []types.Container {
-	cli, err := client.NewEnvClient()
+	rateLimits := NewRateLimits()
+	metrics := NewMetrics(config)
+	pipeline, err := NewPipeline(config, hosts, rateLimits, metrics)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	containers, err := cli.ContainerList(context.Background(), types.ContainerListOptions{})
+	notifiers, err := CreateNotifiers(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if config.Watch {
+		watcher, err := NewWatcher(pipeline, notifiers, config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		var server *Server
+		if config.Serve {
+			server = NewServer(config.Listen, watcher, metrics)
+			err = server.Start()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to start HTTP server, %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		watcher.Watch()
+
+		if server != nil {
+			server.Stop()
+		}
+		rateLimits.Print()
+		return
+	}
+
+	var result CheckResult
+	var references []FileReference
+	if len(config.Files) > 0 {
+		references, err = ReadFileReferences(config.Files)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		result = pipeline.RunImages(ImageNames(references))
+	} else {
+		result, err = pipeline.Run()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	report := NewReport(result, time.Now())
+	report.AttachSources(references)
+
+	err = writeResults(reporter, report, PlanFileUpdates(references, result.ImagesNewerVersions, updateLevel), config)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	notifiers.Notify(result)
+	metrics.ObserveResult(result, time.Now())
 
-	if len(containers) == 0 {
-		fmt.Println("No running containers")
-		os.Exit(0)
+	rateLimits.Print()
+}
+
+// writeResults writes the report and updates checked files. The diff of updates replaces the report on standard
+// output, so that it can be applied with patch or git apply, the report is written only to the output file then.
+func writeResults(reporter Reporter, report Report, updates []FileUpdate, config Config) error {
+	if !config.Diff || config.OutputFile != "" {
+		err := writeReport(reporter, report, config.OutputFile)
+		if err != nil {
+			return fmt.Errorf("failed to write results, %v", err)
+		}
+	}
+
+	if !config.Write && !config.Diff {
+		return nil
 	}
 
-	return containers
+	var diff io.Writer
+	if config.Diff {
+		diff = os.Stdout
+	}
+	err := ApplyFileUpdates(updates, config.Write, diff)
+	if err != nil {
+		return fmt.Errorf("failed to update files, %v", err)
+	}
+	return nil
 }
 
-func (v *VersionChecker) CheckContainersImageTags(containers []types.Container) {
+func writeReport(reporter Reporter, report Report, outputFile string) error {
+	if outputFile == "" {
+		return reporter.Report(os.Stdout, report)
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+
+	err = reporter.Report(file, report)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func setupLogging(config Config) {
+	if config.Verbose {
+		log.SetLevel(log.DebugLevel)
+	}
+}
+
+// CheckContainersImageTags checks images of containers running on given host, which is empty for a single host.
+func (v *VersionChecker) CheckContainersImageTags(host string, containers []types.Container) {
 	for _, container := range containers {
-		v.checkContainerImageTags(container)
+		containerName := containerName(container)
+		fmt.Fprintf(os.Stderr, "Checking %s [%s]\n", container.Image, containerKey(host, containerName))
+
+		v.checkImageTags(container.Image, containerName, host)
 	}
 
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 }
 
-func (v *VersionChecker) checkContainerImageTags(container types.Container) {
-	imageName := container.Image
-	containerName := strings.TrimPrefix(container.Names[0], "/")
-	fmt.Printf("Checking %s [%s]\n", imageName, containerName)
+// CheckImagesTags checks images which are not run by containers, e.g. referenced in files or present on given host.
+func (v *VersionChecker) CheckImagesTags(host string, imageNames []string) {
+	for _, imageName := range imageNames {
+		if host == "" {
+			fmt.Fprintf(os.Stderr, "Checking %s\n", imageName)
+		} else {
+			fmt.Fprintf(os.Stderr, "Checking %s on %s\n", imageName, host)
+		}
+
+		v.checkImageTags(imageName, "", host)
+	}
 
+	fmt.Fprintln(os.Stderr)
+}
+
+func (v *VersionChecker) checkImageTags(imageName string, containerName string, host string) {
 	image, err := getImageDetails(imageName)
 	if err != nil {
-		fmt.Printf("Ignoring %s due to %v\n", imageName, err)
+		log.Debugf("Ignoring %s due to %v", imageName, err)
+		v.storage.addFailed(&ImageFailure{Image: Image{LocalFullName: imageName, Container: containerName, Host: host}, Reason: FailureInvalidImage, Err: err})
 		return
 	}
+	image.Container = containerName
+	image.Host = host
 
 	err = ValidateTagIsSemver(image.Tag)
 	if err != nil {
-		fmt.Printf("Ignoring %s due to %v\n", imageName, err)
+		log.Debugf("Ignoring %s due to %v", imageName, err)
+		v.storage.addFailed(&ImageFailure{Image: image, Reason: FailureUnsupportedTag, Err: err})
 		return
 	}
 
-	status, tags, authUrl, err := v.tagDownloader.DownloadWithoutAuth(image)
-	if err != nil {
-		fmt.Println(err)
+	download := v.download(image)
+	if download.err != nil {
+		log.Debugf("Failed to download tags of %s, %v", imageName, download.err)
+		v.storage.addFailed(&ImageFailure{Image: image, Reason: FailureDownload, Err: download.err})
 		return
 	}
 
-	switch status {
+	switch download.status {
 	case StatusImgSuccessful:
-		v.storage.addSuccessful(&ImageTags{Image: image, Tags: tags})
+		v.storage.addSuccessful(&ImageTags{Image: image, Tags: download.tags})
 	case StatusImgUnauthorized:
-		v.storage.addUnauthorized(&ImageAuthUrl{Image: image, AuthUrl: authUrl})
+		v.storage.addUnauthorized(&ImageAuthUrl{Image: image, AuthUrl: download.authUrl})
 	}
 }
 
+func (v *VersionChecker) download(image Image) *tagDownload {
+	repository := image.Registry + "/" + image.Repository()
+
+	download, present := v.downloads[repository]
+	if !present {
+		download = &tagDownload{}
+		download.status, download.tags, download.authUrl, download.err = v.tagDownloader.DownloadWithoutAuth(image)
+		v.downloads[repository] = download
+	} else {
+		log.Debugf("Reusing tags of %s downloaded for another image", repository)
+	}
+
+	return download
+}
+
+func containerName(container types.Container) string {
+	if len(container.Names) == 0 {
+		return container.ID
+	}
+	return strings.TrimPrefix(container.Names[0], "/")
+}
+
 func getImageDetails(imageName string) (Image, error) {
 	image := Image{LocalFullName: imageName}
 
@@ -155,11 +416,15 @@ func getImageDetails(imageName string) (Image, error) {
 		image.Registry = defaultRegistry
 		image.Author = "library"
 	case 2:
-		image.Registry = defaultRegistry
-		image.Author = segments[0]
+		if isRegistryHost(segments[0]) {
+			image.Registry = segments[0]
+		} else {
+			image.Registry = defaultRegistry
+			image.Author = segments[0]
+		}
 	case 3:
 		registry := segments[0]
-		if !strings.Contains(registry, ".") {
+		if !isRegistryHost(registry) {
 			return Image{}, fmt.Errorf("%s is invalid registry", registry)
 		}
 		image.Registry = registry
@@ -171,6 +436,11 @@ func getImageDetails(imageName string) (Image, error) {
 	return image, nil
 }
 
+// isRegistryHost tells whether the first segment of image name is a registry, following Docker rules.
+func isRegistryHost(segment string) bool {
+	return strings.ContainsAny(segment, ".:") || segment == "localhost"
+}
+
 func splitNameAndTag(nameTag string) (name string, tag string, err error) {
 	split := strings.Split(nameTag, `:`)
 	splitLen := len(split)
@@ -193,6 +463,10 @@ func (is *ImageStorage) addSuccessful(imageTags *ImageTags) {
 	is.Successful = append(is.Successful, imageTags)
 }
 
+func (is *ImageStorage) addFailed(failure *ImageFailure) {
+	is.Failed = append(is.Failed, failure)
+}
+
 func (is *ImageStorage) addUnauthorized(image *ImageAuthUrl) {
 	is.Unauthorized = append(is.Unauthorized, image)
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGetImageDetails(t *testing.T) {
+	cases := map[string]Image{
+		"image:1.0":                     {Registry: defaultRegistry, Author: "library", Name: "image", Tag: "1.0"},
+		"author/image:1.0":              {Registry: defaultRegistry, Author: "author", Name: "image", Tag: "1.0"},
+		"localhost:5000/image:1.0":      {Registry: "localhost:5000", Name: "image", Tag: "1.0"},
+		"registry.com/author/image:1.0": {Registry: "registry.com", Author: "author", Name: "image", Tag: "1.0"},
+	}
+
+	for imageName, expected := range cases {
+		expected.LocalFullName = imageName
+
+		image, err := getImageDetails(imageName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(expected, image) {
+			t.Errorf("Should be %v, but is %v", expected, image)
+		}
+	}
+}
+
+func TestVersionCheckerDownloadsRepositoryOnce(t *testing.T) {
+	var paths []string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/v2/library/image/tags/list" {
+			_, _ = w.Write([]byte(`{"name": "library/image", "tags": ["1.0.0", "1.1.0"]}`))
+		}
+	}))
+	defer mirror.Close()
+
+	mirrors, _ := ParseRegistryMirrors([]string{mirror.URL})
+	apiClient, _ := NewApiClient(Config{Timeout: 5}, NewRateLimits(), nil)
+
+	storage := &ImageStorage{}
+	versionChecker := NewVersionChecker(NewTagDownloader(apiClient, mirrors, NewTokenStore()), storage)
+	versionChecker.CheckImagesTags("web01", []string{"image:1.0.0"})
+	versionChecker.CheckImagesTags("web02", []string{"image:1.1.0"})
+
+	expectedPaths := []string{"/v2/", "/v2/library/image/tags/list"}
+	if !reflect.DeepEqual(expectedPaths, paths) {
+		t.Errorf("Should be %v, but is %v", expectedPaths, paths)
+	}
+
+	var hosts []string
+	for _, imageTags := range storage.Successful {
+		hosts = append(hosts, imageTags.Image.Host)
+	}
+	if expected := []string{"web01", "web02"}; !reflect.DeepEqual(expected, hosts) {
+		t.Errorf("Should be %v, but is %v", expected, hosts)
+	}
+}
@@ -11,22 +11,110 @@ import (
 
 type ImagesNewerVersions []ImageNewerVersions
 
-func (inv ImagesNewerVersions) Print() {
-	for _, imageNewerVersions := range inv {
-		imageNewerVersions.Print()
-	}
-}
-
 type ImageNewerVersions struct {
 	imageName     string
+	image         Image
 	newerVersions []string
+	// metadata holds details of current and newer versions by tag, if they were downloaded.
+	metadata map[string]*ImageMetadata
+	// unavailable marks newer versions that cannot run on the platform, if platforms were checked.
+	unavailable map[string]bool
+	platform    Platform
 }
 
-func (inv ImageNewerVersions) Print() {
+func (inv ImageNewerVersions) Describe() string {
 	if len(inv.newerVersions) > 0 {
-		fmt.Printf("There are new versions of %s! Newer versions: %s\n", inv.imageName, inv.newerVersions)
-	} else {
-		fmt.Printf("%s is up to date\n", inv.imageName)
+		return fmt.Sprintf("There are new versions of %s! Newer versions: %s", inv.imageName, inv.formatNewerVersions())
+	}
+	return fmt.Sprintf("%s is up to date", inv.imageName)
+}
+
+func (inv ImageNewerVersions) formatNewerVersions() string {
+	if inv.metadata == nil && inv.unavailable == nil {
+		return fmt.Sprint(inv.newerVersions)
+	}
+
+	current := inv.metadata[inv.image.Tag]
+
+	var formatted []string
+	for _, newerVersion := range inv.newerVersions {
+		var details []string
+
+		metadata, present := inv.metadata[newerVersion]
+		if present {
+			details = append(details, metadata.Describe(current))
+		}
+
+		if inv.unavailable[newerVersion] {
+			details = append(details, fmt.Sprintf("unavailable for %s", inv.platform))
+		}
+
+		if len(details) == 0 {
+			formatted = append(formatted, newerVersion)
+		} else {
+			formatted = append(formatted, fmt.Sprintf("%s (%s)", newerVersion, strings.Join(details, ", ")))
+		}
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+type UpdateLevel int
+
+const (
+	UpdateNone UpdateLevel = iota
+	UpdatePatch
+	UpdateMinor
+	UpdateMajor
+)
+
+var updateLevelNames = []string{"none", "patch", "minor", "major"}
+
+func (ul UpdateLevel) String() string {
+	return updateLevelNames[ul]
+}
+
+func ParseUpdateLevel(name string) (UpdateLevel, error) {
+	for level, levelName := range updateLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return UpdateLevel(level), nil
+		}
+	}
+	return UpdateNone, fmt.Errorf("%s is invalid update level", name)
+}
+
+// Level returns the highest update level among newer versions.
+func (inv ImageNewerVersions) Level() UpdateLevel {
+	level := UpdateNone
+	for _, newerVersion := range inv.newerVersions {
+		versionLevel := GetUpdateLevel(inv.image.Tag, newerVersion)
+		if versionLevel > level {
+			level = versionLevel
+		}
+	}
+	return level
+}
+
+// GetUpdateLevel tells which segment of the current version changes in the newer version.
+func GetUpdateLevel(current string, newer string) UpdateLevel {
+	currentVersion, err := version.NewSemver(current)
+	if err != nil {
+		return UpdateNone
+	}
+
+	newerVersion, err := version.NewSemver(newer)
+	if err != nil || !newerVersion.GreaterThan(currentVersion) {
+		return UpdateNone
+	}
+
+	currentSegments, newerSegments := currentVersion.Segments(), newerVersion.Segments()
+	switch {
+	case newerSegments[0] != currentSegments[0]:
+		return UpdateMajor
+	case newerSegments[1] != currentSegments[1]:
+		return UpdateMinor
+	default:
+		return UpdatePatch
 	}
 }
 
@@ -52,7 +140,9 @@ func CheckImagesForNewerVersions(storage *ImageStorage, config Config) ImagesNew
 	for _, imageTags := range storage.Successful {
 		imageNewerVersions, err := strategyFunc(imageTags)
 		if err != nil {
-			fmt.Printf("Failed to check image %s for newer versions, %v\n", imageTags.Image.LocalFullName, err)
+			log.Debugf("Failed to check image %s for newer versions, %v", imageTags.Image.LocalFullName, err)
+			storage.addFailed(&ImageFailure{Image: imageTags.Image, Reason: FailureComparison, Err: err})
+			continue
 		}
 
 		imagesNewerVersions = append(imagesNewerVersions, imageNewerVersions)
@@ -71,7 +161,7 @@ func checkImageForAllNewerVersions(imageTags *ImageTags) (ImageNewerVersions, er
 
 	newerVersions := getNewerVersions(versions, constraints)
 
-	return ImageNewerVersions{imageName: imageTags.Image.LocalFullName, newerVersions: newerVersions}, nil
+	return ImageNewerVersions{imageName: imageTags.Image.LocalFullName, image: imageTags.Image, newerVersions: newerVersions}, nil
 }
 
 func checkImageForNewerVersions(imageTags *ImageTags) (ImageNewerVersions, error) {
@@ -89,7 +179,7 @@ func checkImageForNewerVersions(imageTags *ImageTags) (ImageNewerVersions, error
 
 	newerVersions := getNewerVersions(versions, constraints)
 
-	return ImageNewerVersions{imageName: imageTags.Image.LocalFullName, newerVersions: newerVersions}, nil
+	return ImageNewerVersions{imageName: imageTags.Image.LocalFullName, image: imageTags.Image, newerVersions: newerVersions}, nil
 }
 
 func createValidVersionsSortedAsc(tags []string) []*version.Version {
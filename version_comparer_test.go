@@ -20,8 +20,8 @@ func TestCheckImagesForAllNewerVersions(t *testing.T) {
 	imagesNewerVersions := CheckImagesForNewerVersions(storage, config)
 
 	expected := ImagesNewerVersions{
-		{image1.LocalFullName, []string{"0.2.0"}},
-		{image2.LocalFullName, []string{"0.3.0", "1.0.0"}},
+		{imageName: image1.LocalFullName, image: image1, newerVersions: []string{"0.2.0"}},
+		{imageName: image2.LocalFullName, image: image2, newerVersions: []string{"0.3.0", "1.0.0"}},
 	}
 	if !reflect.DeepEqual(expected, imagesNewerVersions) {
 		t.Errorf("Should be %v, but is %v", expected, imagesNewerVersions)
@@ -46,11 +46,31 @@ func TestCheckImagesForNewerVersions(t *testing.T) {
 	imagesNewerVersions := CheckImagesForNewerVersions(storage, config)
 
 	expected := ImagesNewerVersions{
-		{image1.LocalFullName, []string{"3"}},
-		{image2.LocalFullName, []string{"1.2", "1.3"}},
-		{image3.LocalFullName, []string{"0.3.0", "1.0.0"}},
+		{imageName: image1.LocalFullName, image: image1, newerVersions: []string{"3"}},
+		{imageName: image2.LocalFullName, image: image2, newerVersions: []string{"1.2", "1.3"}},
+		{imageName: image3.LocalFullName, image: image3, newerVersions: []string{"0.3.0", "1.0.0"}},
 	}
 	if !reflect.DeepEqual(expected, imagesNewerVersions) {
 		t.Errorf("Should be %v, but is %v", expected, imagesNewerVersions)
 	}
 }
+
+func TestGetUpdateLevel(t *testing.T) {
+	cases := []struct {
+		current  string
+		newer    string
+		expected UpdateLevel
+	}{
+		{"1.2.3", "1.2.4", UpdatePatch},
+		{"1.2.3", "1.3.0", UpdateMinor},
+		{"1.2.3", "2.0.0", UpdateMajor},
+		{"15.3", "15.4", UpdateMinor},
+		{"latest", "1.0.0", UpdateNone},
+	}
+
+	for _, c := range cases {
+		if level := GetUpdateLevel(c.current, c.newer); level != c.expected {
+			t.Errorf("%s -> %s should be %v, but is %v", c.current, c.newer, c.expected, level)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"golang.org/x/net/context"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// Watcher re-runs the pipeline on schedule and reports only changes between runs.
+type Watcher struct {
+	pipeline  *Pipeline
+	notifiers *Notifiers
+	schedule  cron.Schedule
+	signals   chan os.Signal
+	// eventListener is set when containers should be checked on Docker events.
+	eventListener *EventListener
+	// trigger requests a check before the scheduled one.
+	trigger chan struct{}
+
+	state   *CheckResult
+	results *ResultStore
+}
+
+func NewWatcher(pipeline *Pipeline, notifiers *Notifiers, config Config) (*Watcher, error) {
+	schedule, err := createSchedule(config)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &Watcher{
+		pipeline:  pipeline,
+		notifiers: notifiers,
+		schedule:  schedule,
+		signals:   make(chan os.Signal, 1),
+		trigger:   make(chan struct{}, 1),
+		results:   &ResultStore{},
+	}
+
+	if config.Events {
+		if len(pipeline.hosts) > 1 {
+			return nil, fmt.Errorf("events can be watched only on a single Docker host")
+		}
+		eventListener := NewEventListener(pipeline.hosts[0].cli, config.EventsDebounce)
+		watcher.eventListener = &eventListener
+	}
+
+	return watcher, nil
+}
+
+func createSchedule(config Config) (cron.Schedule, error) {
+	if config.Schedule != "" {
+		schedule, err := cron.ParseStandard(config.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %s, %v", config.Schedule, err)
+		}
+		return schedule, nil
+	}
+
+	if config.Interval < time.Second {
+		return nil, fmt.Errorf("interval %v is too short", config.Interval)
+	}
+
+	return cron.Every(config.Interval), nil
+}
+
+// Watch runs checks until SIGINT or SIGTERM is received. Signals received during a check stop watching
+// once the check finishes.
+func (w *Watcher) Watch() {
+	signal.Notify(w.signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(w.signals)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w.check()
+
+	var containerEvents <-chan ContainerEvents
+	if w.eventListener != nil {
+		containerEvents = w.eventListener.Listen(ctx)
+	}
+
+	// Only full checks move the next scheduled one, so that frequent events cannot postpone it.
+	next := w.scheduleNext()
+	for {
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-timer.C:
+			w.check()
+			next = w.scheduleNext()
+		case <-w.trigger:
+			timer.Stop()
+			w.check()
+			next = w.scheduleNext()
+		case batch := <-containerEvents:
+			timer.Stop()
+			w.checkEvents(batch)
+		case sig := <-w.signals:
+			timer.Stop()
+			fmt.Fprintf(os.Stderr, "Received %v, stopping\n", sig)
+			return
+		}
+	}
+}
+
+func (w *Watcher) scheduleNext() time.Time {
+	next := w.schedule.Next(time.Now())
+	fmt.Fprintf(os.Stderr, "Next check at %s\n", next.Format(time.RFC3339))
+	return next
+}
+
+// Trigger requests a check as soon as the current one finishes, requests made in the meantime are merged.
+func (w *Watcher) Trigger() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Watcher) Results() *ResultStore {
+	return w.results
+}
+
+func (w *Watcher) check() {
+	fmt.Fprintf(os.Stderr, "Checking containers at %s\n", time.Now().Format(time.RFC3339))
+
+	result, err := w.pipeline.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to check containers, %v\n", err)
+		return
+	}
+
+	w.update(result)
+}
+
+// checkEvents checks only started containers and forgets destroyed ones.
+func (w *Watcher) checkEvents(batch ContainerEvents) {
+	if w.state == nil {
+		return
+	}
+
+	var result CheckResult
+	if len(batch.Started) > 0 {
+		fmt.Fprintf(os.Stderr, "Checking %d started containers\n", len(batch.Started))
+
+		containers, err := getContainersById(w.pipeline.hosts[0].cli, batch.Started)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get started containers, %v\n", err)
+			return
+		}
+
+		result = w.pipeline.RunContainers(w.pipeline.filters.Filter(containers))
+	}
+
+	var destroyed []string
+	for _, container := range batch.Destroyed {
+		destroyed = append(destroyed, containerKey(w.pipeline.hosts[0].Name, container))
+	}
+
+	w.report(applyContainerChanges(*w.state, result, destroyed))
+}
+
+func (w *Watcher) update(result CheckResult) {
+	if w.state == nil {
+		TextReporter{}.Report(os.Stdout, NewReport(result, time.Now()))
+		w.notifiers.Notify(result)
+		w.state = &result
+		w.publish(result)
+		return
+	}
+
+	w.report(mergeResults(*w.state, result))
+}
+
+func (w *Watcher) report(current CheckResult) {
+	changes := diffResults(*w.state, current)
+	if len(changes) == 0 {
+		fmt.Println("No changes since last check")
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	w.notifiers.Notify(current)
+
+	w.state = &current
+	w.publish(current)
+}
+
+// publish makes the result available to the HTTP server and metrics.
+func (w *Watcher) publish(result CheckResult) {
+	now := time.Now()
+	w.results.Set(result, now)
+	w.pipeline.metrics.ObserveResult(result, now)
+}
+
+// applyContainerChanges creates result of all containers from the previous one, the result of
+// checking only some containers and names of containers that were destroyed.
+func applyContainerChanges(previous CheckResult, partial CheckResult, destroyed []string) CheckResult {
+	current := CheckResult{Containers: make(map[string]string)}
+	for container, image := range previous.Containers {
+		current.Containers[container] = image
+	}
+
+	for _, container := range destroyed {
+		delete(current.Containers, container)
+	}
+	for container, image := range partial.Containers {
+		current.Containers[container] = image
+	}
+
+	for _, inv := range previous.ImagesNewerVersions {
+		container := inv.image.ContainerKey()
+		if _, checked := partial.Containers[container]; checked {
+			continue
+		}
+		if _, present := current.Containers[container]; !present {
+			continue
+		}
+
+		current.ImagesNewerVersions = append(current.ImagesNewerVersions, inv)
+	}
+	current.ImagesNewerVersions = append(current.ImagesNewerVersions, partial.ImagesNewerVersions...)
+
+	for _, failure := range previous.Failures {
+		container := failure.Image.ContainerKey()
+		if _, checked := partial.Containers[container]; checked {
+			continue
+		}
+		if _, present := current.Containers[container]; !present {
+			continue
+		}
+
+		current.Failures = append(current.Failures, failure)
+	}
+	current.Failures = append(current.Failures, partial.Failures...)
+
+	return current
+}
+
+// mergeResults keeps previous newer versions of containers which still run the same image, but could not be
+// checked this time, e.g. due to registry errors, so that they are not reported as changes.
+func mergeResults(previous CheckResult, current CheckResult) CheckResult {
+	checked := make(map[string]bool)
+	for _, inv := range current.ImagesNewerVersions {
+		checked[inv.image.ContainerKey()] = true
+	}
+
+	for _, inv := range previous.ImagesNewerVersions {
+		container := inv.image.ContainerKey()
+		if checked[container] || current.Containers[container] != previous.Containers[container] {
+			continue
+		}
+
+		current.ImagesNewerVersions = append(current.ImagesNewerVersions, inv)
+	}
+
+	return current
+}
+
+// diffResults describes new versions, updated containers and images that are gone since the previous result.
+func diffResults(previous CheckResult, current CheckResult) []string {
+	var changes []string
+
+	for _, container := range sortedContainers(previous.Containers) {
+		previousImage := previous.Containers[container]
+		currentImage, present := current.Containers[container]
+
+		if !present {
+			changes = append(changes, fmt.Sprintf("Container %s running %s is gone", container, previousImage))
+		} else if currentImage != previousImage {
+			changes = append(changes, fmt.Sprintf("Container %s was updated from %s to %s", container, previousImage, currentImage))
+		}
+	}
+
+	for _, container := range sortedContainers(current.Containers) {
+		if _, present := previous.Containers[container]; !present {
+			changes = append(changes, fmt.Sprintf("Container %s running %s was started", container, current.Containers[container]))
+		}
+	}
+
+	previousVersions := make(map[string]map[string]bool)
+	for _, inv := range previous.ImagesNewerVersions {
+		versions := make(map[string]bool)
+		for _, newerVersion := range inv.newerVersions {
+			versions[newerVersion] = true
+		}
+		previousVersions[inv.image.ContainerKey()+"/"+inv.imageName] = versions
+	}
+
+	for _, inv := range current.ImagesNewerVersions {
+		known := previousVersions[inv.image.ContainerKey()+"/"+inv.imageName]
+
+		var newVersions []string
+		for _, newerVersion := range inv.newerVersions {
+			if !known[newerVersion] {
+				newVersions = append(newVersions, newerVersion)
+			}
+		}
+
+		if len(newVersions) > 0 {
+			changes = append(changes, fmt.Sprintf("There are new versions of %s [%s]! New versions: %s", inv.imageName, inv.image.ContainerKey(), newVersions))
+		}
+	}
+
+	return changes
+}
+
+func sortedContainers(containers map[string]string) []string {
+	var names []string
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
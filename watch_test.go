@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestNewerVersions(imageName string, container string, newerVersions ...string) ImageNewerVersions {
+	image, _ := getImageDetails(imageName)
+	image.Container = container
+	return ImageNewerVersions{imageName: imageName, image: image, newerVersions: newerVersions}
+}
+
+func TestDiffResults(t *testing.T) {
+	previous := CheckResult{
+		Containers: map[string]string{"api": "author/api:1.0.0", "db": "postgres:15.3", "cache": "redis:7.0.0"},
+		ImagesNewerVersions: ImagesNewerVersions{
+			newTestNewerVersions("author/api:1.0.0", "api", "1.1.0"),
+			newTestNewerVersions("postgres:15.3", "db", "15.4"),
+		},
+	}
+	current := CheckResult{
+		Containers: map[string]string{"api": "author/api:1.1.0", "db": "postgres:15.3"},
+		ImagesNewerVersions: ImagesNewerVersions{
+			newTestNewerVersions("author/api:1.1.0", "api"),
+			newTestNewerVersions("postgres:15.3", "db", "15.4", "15.5"),
+		},
+	}
+
+	expected := []string{
+		"Container api was updated from author/api:1.0.0 to author/api:1.1.0",
+		"Container cache running redis:7.0.0 is gone",
+		"There are new versions of postgres:15.3 [db]! New versions: [15.5]",
+	}
+
+	changes := diffResults(previous, current)
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("Should be %v, but is %v", expected, changes)
+	}
+}
+
+func TestMergeResultsKeepsVersionsOfUncheckedContainers(t *testing.T) {
+	previous := CheckResult{
+		Containers:          map[string]string{"db": "postgres:15.3"},
+		ImagesNewerVersions: ImagesNewerVersions{newTestNewerVersions("postgres:15.3", "db", "15.4")},
+	}
+	current := CheckResult{Containers: map[string]string{"db": "postgres:15.3"}}
+
+	merged := mergeResults(previous, current)
+
+	if changes := diffResults(previous, merged); len(changes) != 0 {
+		t.Errorf("Should be no changes, but are %v", changes)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+const (
+	contentTypeJson = "application/json"
+	contentTypeText = "text/plain"
+)
+
+type WebhookConfig struct {
+	Url         string
+	Preset      string
+	Template    string
+	ContentType string `mapstructure:"content-type"`
+	Headers     map[string]string
+
+	NotificationFilterConfig `mapstructure:",squash"`
+}
+
+type webhookPreset struct {
+	template    string
+	contentType string
+	headers     map[string]string
+}
+
+// webhookPresets hold body templates of services accepting incoming webhooks.
+var webhookPresets = map[string]webhookPreset{
+	"json":       {template: `{{ json . }}`, contentType: contentTypeJson},
+	"slack":      {template: `{"text": {{ json (printf "%s\n%s" .Title .Summary) }}}`, contentType: contentTypeJson},
+	"mattermost": {template: `{"text": {{ json (printf "%s\n%s" .Title .Summary) }}}`, contentType: contentTypeJson},
+	"discord":    {template: `{"content": {{ json (printf "%s\n%s" .Title .Summary) }}}`, contentType: contentTypeJson},
+	"teams":      {template: `{"title": {{ json .Title }}, "text": {{ json .Summary }}}`, contentType: contentTypeJson},
+	"gotify":     {template: `{"title": {{ json .Title }}, "message": {{ json .Summary }}, "priority": 5}`, contentType: contentTypeJson},
+	"ntfy": {
+		template:    `{{ .Summary }}`,
+		contentType: contentTypeText,
+		headers:     map[string]string{"Title": "New image versions", "Tags": "whale"},
+	},
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		bytes, err := json.Marshal(value)
+		return string(bytes), err
+	},
+}
+
+// WebhookNotifier posts notifications rendered with a template to an URL.
+type WebhookNotifier struct {
+	url         string
+	template    *template.Template
+	contentType string
+	headers     map[string]string
+	http        *http.Client
+}
+
+func NewWebhookNotifier(webhookConfig WebhookConfig, config Config) (*WebhookNotifier, error) {
+	presetName := webhookConfig.Preset
+	if presetName == "" {
+		presetName = "json"
+	}
+
+	preset, present := webhookPresets[presetName]
+	if !present {
+		return nil, fmt.Errorf("unknown preset %s of webhook %s", presetName, webhookConfig.Url)
+	}
+
+	body := preset.template
+	if webhookConfig.Template != "" {
+		body = webhookConfig.Template
+	}
+
+	bodyTemplate, err := template.New(webhookConfig.Url).Funcs(webhookTemplateFuncs).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template of webhook %s, %v", webhookConfig.Url, err)
+	}
+
+	contentType := preset.contentType
+	if webhookConfig.ContentType != "" {
+		contentType = webhookConfig.ContentType
+	}
+
+	headers := make(map[string]string)
+	for name, value := range preset.headers {
+		headers[name] = value
+	}
+	for name, value := range webhookConfig.Headers {
+		headers[name] = value
+	}
+
+	proxy := createProxyFunc(config)
+
+	return &WebhookNotifier{
+		url:         webhookConfig.Url,
+		template:    bodyTemplate,
+		contentType: contentType,
+		headers:     headers,
+		http: &http.Client{
+			Timeout:   time.Duration(config.Timeout) * time.Second,
+			Transport: &http.Transport{Proxy: func(request *http.Request) (*url.URL, error) { return proxy(request.URL) }},
+		},
+	}, nil
+}
+
+func (wn *WebhookNotifier) Name() string {
+	return wn.url
+}
+
+func (wn *WebhookNotifier) Notify(notification Notification) error {
+	var body bytes.Buffer
+	err := wn.template.Execute(&body, notification)
+	if err != nil {
+		return fmt.Errorf("failed to render template, %v", err)
+	}
+
+	request, err := http.NewRequest("POST", wn.url, &body)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", wn.contentType)
+	for name, value := range wn.headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := wn.http.Do(request)
+	if err != nil {
+		return err
+	}
+	discardBody(response)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return nil
+}